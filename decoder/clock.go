@@ -0,0 +1,110 @@
+package decoder
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+//A frame logged by the showinfo filter, its number and what should be its presentation timestamp
+var showinfoRegex = regexp.MustCompile(`Parsed_showinfo.*\bn:\s*(\d+)\s+pts:\s*\S*\s+pts_time:(\S*)`)
+
+//loggedFrame is the number of a frame showinfo logged and its presentation timestamp, valid is false when the timestamp couldn't be read
+type loggedFrame struct {
+	number    uint
+	timestamp time.Duration
+	valid     bool
+}
+
+//FrameClock times decoded frames with the presentation timestamps ffmpeg's showinfo filter logs for them, relative to the first frame.
+//Frames whose log line is missing or malformed are timed from their index at FramesPerSecond instead
+type FrameClock struct {
+	FramesPerSecond int
+	logged          chan loggedFrame
+	pending         loggedFrame
+	hasPending      bool
+	first           time.Duration
+}
+
+//NewFrameClock Creates a clock for a video at fps that holds up to buffer logged frames until they are timed
+func NewFrameClock(fps, buffer int) *FrameClock {
+	var clock FrameClock
+	clock.FramesPerSecond = fps
+	clock.logged = make(chan loggedFrame, buffer)
+	return &clock
+}
+
+//Log reads the timestamp of a frame from a showinfo log line, waiting for room in the buffer unless ctx is done.
+//It returns false for lines that aren't showinfo lines
+func (c *FrameClock) Log(ctx context.Context, line string) bool {
+	matchGroups := showinfoRegex.FindStringSubmatch(line)
+	if matchGroups == nil {
+		return false
+	}
+
+	number, err := strconv.ParseUint(matchGroups[1], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	frame := loggedFrame{number: uint(number)}
+	if seconds, err := strconv.ParseFloat(matchGroups[2], 64); err == nil && !math.IsNaN(seconds) && !math.IsInf(seconds, 0) {
+		frame.timestamp = time.Duration(math.Round(seconds * float64(time.Second)))
+		frame.valid = true
+	}
+
+	select {
+	case c.logged <- frame:
+	case <-ctx.Done():
+	}
+	return true
+}
+
+//Close Marks the end of the log, frames timed after it are timed from their index
+func (c *FrameClock) Close() {
+	close(c.logged)
+}
+
+//Time returns the timestamp of the frame at index, waiting for its log line until the log is closed.
+//Frames must be timed in order
+func (c *FrameClock) Time(index uint) time.Duration {
+	for {
+		if !c.hasPending {
+			frame, ok := <-c.logged
+			if !ok {
+				break
+			}
+			c.pending, c.hasPending = frame, true
+		}
+
+		//Lines of frames that were never read are skipped
+		if c.pending.number < index {
+			c.hasPending = false
+			continue
+		}
+
+		//A line of a later frame means this frame's line is missing, the later frame still needs it
+		if c.pending.number > index || !c.pending.valid {
+			break
+		}
+
+		c.hasPending = false
+		if index == 0 {
+			c.first = c.pending.timestamp
+		}
+		return c.pending.timestamp - c.first
+	}
+
+	if index == 0 {
+		c.first = 0
+	}
+	return frameTimestamp(index, c.FramesPerSecond)
+}
+
+//discard throws away the rest of the log so whatever is logging can finish
+func (c *FrameClock) discard() {
+	for range c.logged {
+	}
+}
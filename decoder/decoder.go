@@ -2,48 +2,76 @@ package decoder
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //Command line magic for ffmpeg and ffprobe
 const _FFProbeCommnand = "ffprobe"
-const _FFProbeArgs = "[filename] -v quiet -print_format json -show_format -show_streams"
+const _FFProbeArgs = "[filename] -v error -select_streams v:0 -print_format json -show_format -show_streams"
 const _FFMPEGCommand string = "ffmpeg"
-const _FFMPEGArgs string = "-i [filename] -an -pix_fmt rgb24 -c:v rawvideo -map 0:v -f image2pipe -"
+const _FFMPEGArgs string = "-i [filename] -an -vf showinfo -fps_mode passthrough -pix_fmt rgb24 -c:v rawvideo -map 0:v -f image2pipe -"
 const _FFMPEGArgs480p = "-s hd480"
-const _FFMPEGArgs30fps = "-r 30 -framerate 30"
+
+//Streams aren't probed, so they are scaled to hd480 by a filter that only applies to those taller than 480 lines
+const _FFMPEGStreamFilter480p = "scale=w='if(gt(ih,480),852,iw)':h='if(gt(ih,480),480,ih)'"
+
+//Input options that stop ffmpeg from buffering a live stream, and that read a file at its native frame rate
+const _FFMPEGLowDelayArgs = "-fflags nobuffer -flags low_delay"
+const _FFMPEGRealtimeArgs = "-re"
+
+//What stdin is called when decoding a stream, the name reports of it get, and what ffmpeg calls it
+const _StdinName = "-"
+const _StdinReportName = "stdin"
+const _FFMPEGStdin = "pipe:0"
 
 const _FrameBufferDefaultSize = 30
 
+//Extra room for frame timestamps logged before their frames are read
+const _TimestampBufferPadding = 64
+
 //Resolution and FPS Finding Regex
 var resolutionRegex = regexp.MustCompile(`rgb24, (\d*)x(\d*)`)
-var fpsRegex = regexp.MustCompile(`(\d*) fps`)
+var fpsRegex = regexp.MustCompile(`(\d+(?:\.\d+)?) fps`)
+
+//Resolution of the first video stream ffmpeg logs, the input's, before any scaling
+var inputResolutionRegex = regexp.MustCompile(`Video: .*?, (\d+)x(\d+)`)
 
 //Decoder Video decoder with ffmpeg as the frame source
 type Decoder struct {
 	FileName                string
 	FrameWidth, FrameHeight int
 	FramesPerSecond         int
+	TotalFrames             int           //Frames in the video according to ffprobe, 0 when unknown
+	Duration                time.Duration //Length of the video according to ffprobe, 0 when unknown
 	FrameBufferCacheSize    int
-	ConvertedTo30FPS        bool
 	ConvertedTo480p         bool
+	LowDelay                bool      //Ask ffmpeg to buffer as little of a stream as it can, for live streams
+	Realtime                bool      //Read the input at its native frame rate, so a file is decoded as if it were live
+	stream                  bool      //Input is a stream that can't be probed with ffprobe first
+	input                   io.Reader //Fed to ffmpeg on stdin instead of reading FileName, if set
 	opened                  bool
-	decoderOpened           bool
-	caching                 bool
 	cmdString               string
 	ffmpegProcess           *exec.Cmd
 	frameSource             io.ReadCloser
 	frameBuffer             chan Frame
-	signalUserCloseDecoder  chan interface{}
-	signalDecoderClosed     chan interface{}
+	clock                   *FrameClock
+	signalDecoderClosed     chan interface{} //Closed once no more frames will be produced and ffmpeg has exited
 	rawFrameSize            int
+	stderrTail              *logTail           //End of ffmpeg's log, only read once the clock's log is closed
+	ctx                     context.Context    //Context the decoder was started with
+	cancel                  context.CancelFunc //Stops decoding and kills ffmpeg
+	err                     error              //Why decoding stopped early, only set before signalDecoderClosed is closed
 }
 
 //NewDecoder Creates a new video decoder for the given file
@@ -55,70 +83,140 @@ func NewDecoder(fileName string) Decoder {
 	return decoder
 }
 
+//NewStreamDecoder Creates a new video decoder for a live stream, an ffmpeg URL, a named pipe or - for stdin.
+//Streams are only read once by ffmpeg, so they aren't probed with ffprobe and their length is unknown
+func NewStreamDecoder(input string) Decoder {
+	if input == _StdinName {
+		return NewDecoderFromReader(_StdinReportName, os.Stdin)
+	}
+
+	decoder := NewDecoder(input)
+	decoder.stream = true
+	return decoder
+}
+
+//NewDecoderFromReader Creates a new video decoder feeding stream to ffmpeg, name is used for reports.
+//The video can only be read once, so it isn't probed with ffprobe and its length is unknown.
+//Formats ffmpeg has to seek in, such as MP4 files with their index at the end, can't be decoded from a stream
+func NewDecoderFromReader(name string, stream io.Reader) Decoder {
+	decoder := NewDecoder(name)
+	decoder.stream = true
+	decoder.input = stream
+	return decoder
+}
+
 //Start opens the stream and begins decoding the video
 func (f *Decoder) Start() error {
+	return f.StartContext(context.Background())
+}
+
+//StartContext opens the stream and begins decoding the video, ffmpeg is killed when ctx is done
+func (f *Decoder) StartContext(ctx context.Context) error {
 
 	//Already in process
 	if f.IsOpen() {
 		return errors.New("Decoder has already been started")
 	}
 
-	//Check if file exists
-	if _, err := os.Stat(f.FileName); err != nil {
-		return err
-	}
+	var arguments []string
+	if f.stream {
+		input := f.FileName
+		if f.input != nil {
+			input = _FFMPEGStdin
+		}
+		arguments = createStreamFFMPegArguments(input, f.LowDelay, f.Realtime)
+	} else {
+		//Check if file exists
+		if _, err := os.Stat(f.FileName); err != nil {
+			return err
+		}
 
-	fileHeight, fileFps, err := probeFileInformation(f.FileName)
+		info, err := probeFileInformation(ctx, f.FileName)
 
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			return contextError(ctx, err)
+		}
 
-	f.ConvertedTo30FPS = fileFps > 30
-	f.ConvertedTo480p = fileHeight > 480
+		f.ConvertedTo480p = info.Height > 480
+		f.TotalFrames = info.TotalFrames
+		f.Duration = info.Duration
 
-	arguments := createFFMPegArguments(f.FileName, f.ConvertedTo30FPS, f.ConvertedTo480p)
+		arguments = createFFMPegArguments(f.FileName, f.ConvertedTo480p, f.Realtime)
+	}
 
-	ffmpegProcess := exec.Command(_FFMPEGCommand, arguments...)
+	decodeCtx, cancel := context.WithCancel(ctx)
+	ffmpegProcess := exec.CommandContext(decodeCtx, _FFMPEGCommand, arguments...)
 	f.ffmpegProcess = ffmpegProcess
 
+	//Wait would hold out for a reader that never returns, so the input is copied by hand
+	var stdin io.WriteCloser
+	if f.input != nil {
+		pipe, err := ffmpegProcess.StdinPipe()
+		if err != nil {
+			cancel()
+			return err
+		}
+		stdin = pipe
+	}
+
 	stdout, err := ffmpegProcess.StdoutPipe()
 	if err != nil {
+		cancel()
 		return err
 	}
 
 	stderr, err := ffmpegProcess.StderrPipe()
 	if err != nil {
+		cancel()
 		return err
 	}
 
 	err = ffmpegProcess.Start()
 	if err != nil {
-		return err
+		cancel()
+		return commandError(err)
 	}
 
-	height, width, fps, err := probeStreamInfo(stderr)
-	stderr.Close()
-	if err != nil {
-		return err
+	if stdin != nil {
+		go feedInput(stdin, f.input)
 	}
 
+	//showinfo can log the first frames before the stream info, so timestamps are collected from the start
+	f.clock = NewFrameClock(0, f.FrameBufferCacheSize+_TimestampBufferPadding)
+	f.stderrTail = &logTail{}
+	stderrReader := bufio.NewReader(stderr)
+
+	info, err := probeStreamInfo(ctx, stderrReader, f.clock, f.stderrTail)
 	if err != nil {
-		return err
+		//ffmpeg already gave up if its log ended, let it exit so its exit code explains why
+		if err != io.EOF {
+			cancel()
+		}
+		waitErr := ffmpegProcess.Wait()
+		cancel()
+		return contextError(ctx, newDecodeError(_FFMPEGCommand, waitErr, f.stderrTail, err))
 	}
 
-	f.FrameHeight = height
-	f.FrameWidth = width
-	f.FramesPerSecond = fps
+	//ffmpeg keeps logging a timestamp for every frame
+	go readFrameTimestamps(decodeCtx, stderrReader, f.clock, f.stderrTail)
+
+	f.FrameHeight = info.Height
+	f.FrameWidth = info.Width
+	f.FramesPerSecond = info.FramesPerSecond
+	f.clock.FramesPerSecond = info.FramesPerSecond
+	if f.stream {
+		f.ConvertedTo480p = info.InputHeight > 480
+	}
 
 	f.frameSource = stdout
 	f.rawFrameSize = f.FrameHeight * f.FrameWidth * 3
 	f.frameBuffer = make(chan Frame, f.FrameBufferCacheSize)
-	f.signalDecoderClosed = make(chan interface{}, 1)
-	f.signalUserCloseDecoder = make(chan interface{}, 1)
+	f.signalDecoderClosed = make(chan interface{})
+	f.ctx = ctx
+	f.cancel = cancel
 
 	//Concurrently fill the framebuffer
-	go cacheFrameBuffer(f)
+	go cacheFrameBuffer(decodeCtx, f)
 	f.opened = true
 	return nil
 }
@@ -128,124 +226,241 @@ func (f *Decoder) IsOpen() bool {
 	return f.opened || len(f.frameBuffer) > 0
 }
 
-//Close Closes the video decoder
+//Close Closes the video decoder, killing ffmpeg and waiting for it to exit
 func (f *Decoder) Close() {
-	if f.IsOpen() {
-		f.signalUserCloseDecoder <- nil
+	if !f.opened {
+		return
+	}
+
+	f.opened = false
+	f.cancel()
+	<-f.signalDecoderClosed
+}
+
+//Dimensions returns the width and height of the decoded frames
+func (f *Decoder) Dimensions() (int, int) {
+	return f.FrameWidth, f.FrameHeight
+}
+
+//FrameRate returns the frames per second of the decoded video
+func (f *Decoder) FrameRate() int {
+	return f.FramesPerSecond
+}
+
+//Length returns how many frames the video has and how long it plays for, as ffprobe reported them.
+//Containers that don't store a frame count have it estimated from the duration
+func (f *Decoder) Length() (int, time.Duration) {
+	if f.TotalFrames == 0 && f.Duration > 0 && f.FramesPerSecond > 0 {
+		return int(math.Round(f.Duration.Seconds() * float64(f.FramesPerSecond))), f.Duration
 	}
+	return f.TotalFrames, f.Duration
+}
+
+//Name returns the file being decoded
+func (f *Decoder) Name() string {
+	return f.FileName
 }
 
 //NextFrame gets the next frame of the video
 func (f *Decoder) NextFrame() (Frame, error) {
+	return f.NextFrameContext(context.Background())
+}
+
+//NextFrameContext gets the next frame of the video, giving up with ctx.Err() when ctx is done first
+func (f *Decoder) NextFrameContext(ctx context.Context) (Frame, error) {
+
+	//Nothing would ever arrive
+	if f.signalDecoderClosed == nil {
+		return Frame{}, ErrNotStarted
+	}
 
-	//We may be done decoding frames
-	if len(f.frameBuffer) == 0 {
+	//We arnt empty, so who cares if ffmpeg is still running
+	select {
+	case fr := <-f.frameBuffer:
+		return fr, nil
+	default:
+	}
+
+	select {
+	//Decoder may be producing frames
+	case fr := <-f.frameBuffer:
+		return fr, nil
+	//Signaled by decoder that no more frames will be produced
+	case <-f.signalDecoderClosed:
+		//Frames may have been buffered right before the decoder stopped
 		select {
-		//Decoder may be producing frames
 		case fr := <-f.frameBuffer:
 			return fr, nil
-		//Signaled by decoder that no more frames will be produced
-		case <-f.signalDecoderClosed:
-			//So no more frames left
-			return Frame{}, errors.New("EOF")
+		default:
+		}
+		if f.err != nil {
+			return Frame{}, f.err
 		}
+		//So no more frames left
+		return Frame{}, io.EOF
+	case <-ctx.Done():
+		return Frame{}, ctx.Err()
 	}
-
-	//We arnt empty, so who cares if ffmpeg is still running
-	return <-f.frameBuffer, nil
 }
 
-//cacheFrameBuffer decodes video frames from ffmpeg and places them in the buffer
-func cacheFrameBuffer(f *Decoder) {
+//cacheFrameBuffer decodes video frames from ffmpeg and places them in the buffer until ffmpeg is done
+//or ctx is cancelled, then waits for ffmpeg to exit and records why decoding stopped
+func cacheFrameBuffer(ctx context.Context, f *Decoder) {
 	var fIndex uint
+	var readErr error
 	frameBuffer := f.frameBuffer
-	f.caching = true
-	for f.IsOpen() {
-		if frame, err := f.nextSourceFrame(); err == nil {
-			frame.Index = fIndex
-			fIndex++
-			select {
-			case <-f.signalUserCloseDecoder:
-				f.finalize()
-				break
-			case frameBuffer <- frame:
-			}
 
-		} else {
-			f.opened = false
+caching:
+	for {
+		frame, err := f.nextSourceFrame()
+		if err != nil {
+			readErr = err
 			break
 		}
+
+		frame.Index = fIndex
+		frame.Timestamp = f.clock.Time(fIndex)
+		fIndex++
+
+		select {
+		case <-ctx.Done():
+			break caching
+		case frameBuffer <- frame:
+		}
+	}
+
+	//Let the stderr reader finish so ffmpeg can be reaped
+	f.clock.discard()
+	waitErr := f.ffmpegProcess.Wait()
+	stopped := ctx.Err() != nil
+	f.cancel()
+
+	switch {
+	case f.ctx.Err() != nil:
+		//Cancelled by the caller
+		f.err = f.ctx.Err()
+	case stopped:
+		//Closed, so ffmpeg was killed on purpose
+	case waitErr != nil:
+		f.err = newDecodeError(_FFMPEGCommand, waitErr, f.stderrTail, nil)
+	case readErr == io.ErrUnexpectedEOF:
+		//ffmpeg ended part way through a frame
+		f.err = ErrTruncated
+	case readErr != io.EOF:
+		f.err = readErr
 	}
-	f.caching = false
-	f.signalDecoderClosed <- nil
+	close(f.signalDecoderClosed)
 }
 
-//nextFrame Gets the next frame in a stream
+//nextSourceFrame Reads the next frame from ffmpeg, its index and timestamp are set by the caller
 func (f *Decoder) nextSourceFrame() (Frame, error) {
 	var frame Frame
 	amountToGrab := f.rawFrameSize
 
 	buffer := make([]byte, amountToGrab, amountToGrab)
-	amount, err := io.ReadFull(f.frameSource, buffer)
+	_, err := io.ReadFull(f.frameSource, buffer)
 
-	if err != nil || amount != amountToGrab {
+	if err != nil {
 		return frame, err
 	}
 
 	frame.pixels = buffer
 	frame.Width = f.FrameWidth
 	frame.Height = f.FrameHeight
-	frame.Index = 0
 	return frame, nil
 }
 
-//finalize Clears the frame buffer and closes the decoding stream
-func (f *Decoder) finalize() {
-	close(f.frameBuffer)
-	f.opened = false
-	f.frameSource.Close()
-	f.decoderOpened = false
+//commandError reports a missing ffmpeg or ffprobe as ErrFFmpegNotFound
+func commandError(err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrFFmpegNotFound
+	}
+	return err
+}
+
+//contextError prefers the context's error when the context ended whatever failed
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
 }
 
 //createFFMPegArguments creates command line magic with the given options for the video fileName
-func createFFMPegArguments(fileName string, fps30, conv480p bool) []string {
+func createFFMPegArguments(fileName string, conv480p, realtime bool) []string {
 	args := strings.Split(_FFMPEGArgs, " ")
 
-	args[1] = fileName
-
 	magic := make([]string, 0)
-	if fps30 {
-		magic = append(magic, strings.Split(_FFMPEGArgs30fps, " ")...)
-	}
-
 	if conv480p {
 		magic = append(magic, strings.Split(_FFMPEGArgs480p, " ")...)
 	}
 
 	fullargs := make([]string, 0)
-	fullargs = append(fullargs, args[:2]...)
-	fullargs = append(fullargs, magic...)
-	fullargs = append(fullargs, args[2:]...)
+	if realtime {
+		fullargs = append(fullargs, _FFMPEGRealtimeArgs)
+	}
+	for _, arg := range args {
+		if arg == "[filename]" {
+			fullargs = append(fullargs, fileName)
+			fullargs = append(fullargs, magic...)
+			continue
+		}
+		fullargs = append(fullargs, arg)
+	}
 
 	return fullargs
 }
 
-//probeFileInformation retrieves the hieght, width, and fps from a video file using ffprobe
-func probeFileInformation(fileLocation string) (int, int, error) {
-	var height, fps int
+//createStreamFFMPegArguments creates command line magic for a stream, which is scaled to 480p by a filter as its height isn't known yet
+func createStreamFFMPegArguments(input string, lowDelay, realtime bool) []string {
+	fullargs := make([]string, 0)
+	if lowDelay {
+		fullargs = append(fullargs, strings.Split(_FFMPEGLowDelayArgs, " ")...)
+	}
+	for _, arg := range createFFMPegArguments(input, false, realtime) {
+		if arg == "showinfo" {
+			arg = _FFMPEGStreamFilter480p + ",showinfo"
+		}
+		fullargs = append(fullargs, arg)
+	}
+
+	return fullargs
+}
+
+//feedInput copies input to ffmpeg's stdin until input ends or ffmpeg exits, then closes stdin so ffmpeg sees the end of the video
+func feedInput(stdin io.WriteCloser, input io.Reader) {
+	io.Copy(stdin, input)
+	stdin.Close()
+}
+
+//fileInformation what ffprobe found out about a video file
+type fileInformation struct {
+	Height          int
+	FramesPerSecond int
+	TotalFrames     int           //0 when the container doesn't store it
+	Duration        time.Duration //0 when the container doesn't store it
+}
+
+//probeFileInformation retrieves the hieght, fps, and length of a video file using ffprobe, ffprobe is killed when ctx is done.
+//Files ffprobe can't read fail with a DecodeError, files without video with ErrNoVideoStream
+func probeFileInformation(ctx context.Context, fileLocation string) (fileInformation, error) {
+	var fileInfo fileInformation
 	args := strings.Split(_FFProbeArgs, " ")
 	args[0] = fileLocation
-	probe := exec.Command(_FFProbeCommnand, args...)
+	probe := exec.CommandContext(ctx, _FFProbeCommnand, args...)
+
+	var stderr strings.Builder
+	probe.Stderr = &stderr
 
 	reader, err := probe.StdoutPipe()
 
 	if err != nil {
-		return height, fps, err
+		return fileInfo, err
 	}
 
 	err = probe.Start()
 	if err != nil {
-		return height, fps, err
+		return fileInfo, commandError(err)
 	}
 
 	jsonDecoder := json.NewDecoder(reader)
@@ -253,45 +468,99 @@ func probeFileInformation(fileLocation string) (int, int, error) {
 	type Streams struct {
 		Height     int    `json:"height"`
 		RFrameRate string `json:"r_frame_rate"`
+		NbFrames   string `json:"nb_frames"`
+		Duration   string `json:"duration"`
+	}
+
+	type Format struct {
+		Duration string `json:"duration"`
 	}
 
 	type Info struct {
 		Streams []Streams `json:"streams"`
+		Format  Format    `json:"format"`
 	}
 
 	var info Info
-	jsonDecoder.Decode(&info)
+	jsonErr := jsonDecoder.Decode(&info)
+	io.Copy(ioutil.Discard, reader)
+	waitErr := probe.Wait()
+
+	if waitErr != nil || jsonErr != nil {
+		tail := &logTail{}
+		for _, line := range strings.Split(stderr.String(), "\n") {
+			tail.add(line)
+		}
+		return fileInfo, newDecodeError(_FFProbeCommnand, waitErr, tail, jsonErr)
+	}
+
+	if len(info.Streams) == 0 {
+		return fileInfo, ErrNoVideoStream
+	}
 
 	stream0 := info.Streams[0]
-	height = stream0.Height
-	fps = int(calculateFpsFromRatio(stream0.RFrameRate))
-	return height, fps, nil
+	fileInfo.Height = stream0.Height
+	fileInfo.FramesPerSecond = int(calculateFpsFromRatio(stream0.RFrameRate))
+
+	//Not every container knows these, the stream's duration is more precise than the container's
+	fileInfo.TotalFrames, _ = strconv.Atoi(stream0.NbFrames)
+	fileInfo.Duration = parseSeconds(stream0.Duration)
+	if fileInfo.Duration == 0 {
+		fileInfo.Duration = parseSeconds(info.Format.Duration)
+	}
+	return fileInfo, nil
 }
 
-//probeStreamInfo retrieves the video hieght, width, and fps from an ffmpeg stderr stream
-func probeStreamInfo(stderr io.ReadCloser) (int, int, int, error) {
-	height, width, fps := -1, -1, -1
+//parseSeconds parses a number of seconds logged by ffprobe, such as 5.005000, 0 when there is none
+func parseSeconds(seconds string) time.Duration {
+	parsed, err := strconv.ParseFloat(seconds, 64)
+	if err != nil || parsed < 0 {
+		return 0
+	}
+	return time.Duration(parsed * float64(time.Second))
+}
 
-	reader := bufio.NewReader(stderr)
+//streamInformation what ffmpeg logged about the video it is decoding
+type streamInformation struct {
+	Width, Height   int //Size of the decoded frames
+	FramesPerSecond int
+	InputHeight     int //Height of the video before it was scaled, 0 when it wasn't logged
+}
+
+//probeStreamInfo retrieves the video hieght, width, and fps from an ffmpeg stderr stream,
+//frame timestamps logged along the way are sent to clock and the rest is kept in tail
+func probeStreamInfo(ctx context.Context, reader *bufio.Reader, clock *FrameClock, tail *logTail) (streamInformation, error) {
+	var info streamInformation
+	height, width, fps := -1, -1, -1
 
 	//Read until we have all variables
 	for fps == -1 || width == -1 || height == -1 {
 		str, err := reader.ReadString('\n')
 
 		if err != nil {
-			return height, width, fps, err
+			return info, err
+		}
+
+		if clock.Log(ctx, str) {
+			continue
+		}
+		tail.add(str)
+
+		//The input is logged before the output
+		if info.InputHeight == 0 && inputResolutionRegex.MatchString(str) {
+			info.InputHeight, _ = strconv.Atoi(inputResolutionRegex.FindStringSubmatch(str)[2])
 		}
 
 		//find resolution
 		if resolutionRegex.MatchString(str) {
 			matchGroups := resolutionRegex.FindStringSubmatch(str)
 			if resx, err := strconv.Atoi(matchGroups[len(matchGroups)-2]); err != nil {
-				return height, width, fps, err
+				return info, err
 			} else {
 				width = resx
 			}
 			if resy, err := strconv.Atoi(matchGroups[len(matchGroups)-1]); err != nil {
-				return height, width, fps, err
+				return info, err
 			} else {
 				height = resy
 			}
@@ -299,15 +568,33 @@ func probeStreamInfo(stderr io.ReadCloser) (int, int, int, error) {
 
 		//Find frames per second
 		if fpsRegex.MatchString(str) {
-			if parsedFps, err := strconv.Atoi(fpsRegex.FindStringSubmatch(str)[1]); err != nil {
-				return height, width, fps, err
+			if parsedFps, err := strconv.ParseFloat(fpsRegex.FindStringSubmatch(str)[1], 64); err != nil {
+				return info, err
 			} else {
-				fps = parsedFps
+				fps = int(math.Round(parsedFps))
 			}
 		}
 	}
 
-	return height, width, fps, nil
+	info.Width, info.Height, info.FramesPerSecond = width, height, fps
+	return info, nil
+}
+
+//readFrameTimestamps sends the timestamp of every frame ffmpeg logs to clock until ffmpeg exits,
+//every other line is kept in tail. Once ctx is done timestamps are thrown away
+func readFrameTimestamps(ctx context.Context, reader *bufio.Reader, clock *FrameClock, tail *logTail) {
+	for {
+		str, err := reader.ReadString('\n')
+
+		if !clock.Log(ctx, str) {
+			tail.add(str)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+	clock.Close()
 }
 
 //calculateFpsFromRatio takes a ratio string from FFMpeg (ex: Frames/Seconds) and converts it to fps
@@ -0,0 +1,99 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+//How many lines of ffmpeg's log are kept to explain a failure
+const _StderrTailLines = 20
+
+//ErrNoVideoStream the file has no video stream to analyze
+var ErrNoVideoStream = errors.New("No video stream found")
+
+//ErrFFmpegNotFound ffmpeg or ffprobe is not installed or not on $PATH
+var ErrFFmpegNotFound = errors.New("ffmpeg or ffprobe was not found, make sure both are on $PATH")
+
+//ErrDecodeFailed ffmpeg or ffprobe could not decode the video, the error is a *DecodeError with the details
+var ErrDecodeFailed = errors.New("Failed to decode video")
+
+//ErrNotStarted frames were asked for before the source was started
+var ErrNotStarted = errors.New("Frame source has not been started")
+
+//ErrTruncated the video ended part way through a frame, it is also an io.ErrUnexpectedEOF
+var ErrTruncated = fmt.Errorf("Video is truncated: %w", io.ErrUnexpectedEOF)
+
+//DecodeError describes why ffmpeg or ffprobe failed, errors.Is(err, ErrDecodeFailed) is true for every DecodeError
+type DecodeError struct {
+	Command  string //ffmpeg or ffprobe
+	ExitCode int    //Exit code of the command, -1 if it never exited normally
+	Stderr   string //The last lines the command logged
+	Err      error  //The underlying error, if any
+}
+
+//Error describes the failure along with the end of the command's log
+func (e *DecodeError) Error() string {
+	message := ErrDecodeFailed.Error() + ", " + e.Command + " exited with code " + fmt.Sprint(e.ExitCode)
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	if e.Stderr != "" {
+		message += "\n" + e.Stderr
+	}
+	return message
+}
+
+//Is makes every DecodeError match ErrDecodeFailed
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecodeFailed
+}
+
+//Unwrap returns the underlying error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+//newDecodeError describes a failed command from what Wait returned and the end of its log,
+//err is what went wrong reading the command's output, if anything
+func newDecodeError(command string, waitErr error, tail *logTail, err error) *DecodeError {
+	decodeErr := &DecodeError{Command: command, ExitCode: -1, Stderr: tail.String()}
+
+	var exitErr *exec.ExitError
+	if waitErr == nil {
+		decodeErr.ExitCode = 0
+	} else if errors.As(waitErr, &exitErr) {
+		decodeErr.ExitCode = exitErr.ExitCode()
+	} else {
+		decodeErr.Err = waitErr
+	}
+
+	if err != nil && err != io.EOF {
+		decodeErr.Err = err
+	}
+	return decodeErr
+}
+
+//logTail keeps the last lines of a log
+type logTail struct {
+	lines []string
+}
+
+//add keeps line, forgetting the oldest line when full
+func (t *logTail) add(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return
+	}
+	if len(t.lines) == _StderrTailLines {
+		t.lines = t.lines[1:]
+	}
+	t.lines = append(t.lines, line)
+}
+
+//String joins the kept lines
+func (t *logTail) String() string {
+	return strings.Join(t.lines, "\n")
+}
@@ -1,10 +1,26 @@
 package decoder
 
+import (
+	"image"
+	"image/color"
+	"math"
+	"time"
+)
+
 //Frame 2D Image Frame
 type Frame struct {
-	pixels        []byte //pixel container
-	Height, Width int    //Height and Width for the current frame
-	Index         uint   //The frame index
+	pixels        []byte        //pixel container
+	yuv           *yuvPlanes    //planar pixel container for frames from YUV sources, used when pixels is nil
+	Height, Width int           //Height and Width for the current frame
+	Index         uint          //The frame index
+	Timestamp     time.Duration //The presentation time of the frame
+}
+
+//yuvPlanes Planar YCbCr pixels, the chroma planes may be subsampled or missing for monochrome frames
+type yuvPlanes struct {
+	Y, Cb, Cr                 []byte
+	ChromaWidth, ChromaHeight int
+	FullRange                 bool
 }
 
 //Pixel Reperesents colored element a within a Frame
@@ -12,9 +28,169 @@ type Pixel struct {
 	Red, Green, Blue int
 }
 
+//limitedLumaLookup expands limited range (16-235) luma to full range
+var limitedLumaLookup = createRangeLookup(16, 235)
+
+//limitedChromaLookup expands limited range (16-240) chroma to full range
+var limitedChromaLookup = createRangeLookup(16, 240)
+
+//NewFrame Creates a frame from packed rgb24 pixels, 3 bytes per pixel row by row
+func NewFrame(width, height int, pixels []byte) Frame {
+	var frame Frame
+	frame.Width = width
+	frame.Height = height
+	frame.pixels = pixels
+	return frame
+}
+
+//NewFrameFromImage Creates a frame from any image, such as a decoded PNG. Its pixels are copied, and
+//transparent areas end up drawn over black. Frames are images too, so they can be saved with image/png
+func NewFrameFromImage(img image.Image) Frame {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	pixels := make([]byte, width*height*3)
+
+	switch source := img.(type) {
+	case Frame:
+		copy(pixels, source.Pixels())
+	case *Frame:
+		copy(pixels, source.Pixels())
+	case *image.RGBA:
+		//Already premultiplied, so already drawn over black
+		for y := 0; y < height; y++ {
+			row := source.Pix[source.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+			for x := 0; x < width; x++ {
+				copy(pixels[(y*width+x)*3:(y*width+x)*3+3], row[x*4:x*4+3])
+			}
+		}
+	default:
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+				position := (y*width + x) * 3
+				pixels[position], pixels[position+1], pixels[position+2] = byte(r>>8), byte(g>>8), byte(b>>8)
+			}
+		}
+	}
+
+	return NewFrame(width, height, pixels)
+}
+
+//ColorModel Returns the color model of frames, which is opaque RGBA
+func (f Frame) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+//Bounds Returns the area of the frame, which starts at 0,0
+func (f Frame) Bounds() image.Rectangle {
+	return image.Rect(0, 0, f.Width, f.Height)
+}
+
+//At Returns the color of the pixel at x,y, or transparent black outside the frame
+func (f Frame) At(x, y int) color.Color {
+	if x < 0 || y < 0 || x >= f.Width || y >= f.Height {
+		return color.RGBA{}
+	}
+	pixel := f.GetRGB(x, y)
+	return color.RGBA{uint8(pixel.Red), uint8(pixel.Green), uint8(pixel.Blue), 255}
+}
+
 //GetRGB Returns the Pixel at x,y in a frame
 func (f *Frame) GetRGB(x, y int) Pixel {
+	if f.pixels == nil && f.yuv != nil {
+		return f.yuv.getRGB(x, y, f.Width, f.Height)
+	}
+
 	//Every pixel is reperesented by 3 bytes, each in the RGB spectrum
-	position := y*f.Width + x*3
+	position := (y*f.Width + x) * 3
 	return Pixel{int(f.pixels[position]), int(f.pixels[position+1]), int(f.pixels[position+2])}
 }
+
+//Pixels Returns the packed rgb24 pixels of the frame, 3 bytes per pixel row by row. Frames are shared, so the pixels must not be modified.
+//Frames from YUV sources are converted to RGB on every call
+func (f *Frame) Pixels() []byte {
+	if f.pixels != nil || f.yuv == nil {
+		return f.pixels
+	}
+
+	pixels := make([]byte, f.Width*f.Height*3)
+	for y := 0; y < f.Height; y++ {
+		f.yuv.fillRow(pixels[y*f.Width*3:(y+1)*f.Width*3], y, f.Width, f.Height)
+	}
+	return pixels
+}
+
+//Row Returns the packed rgb24 pixels of row y, 3 bytes per pixel. Frames are shared, so the row must not be modified.
+//Rows of frames from YUV sources are converted to RGB on every call
+func (f *Frame) Row(y int) []byte {
+	stride := f.Width * 3
+	if f.pixels != nil || f.yuv == nil {
+		return f.pixels[y*stride : (y+1)*stride]
+	}
+
+	row := make([]byte, stride)
+	f.yuv.fillRow(row, y, f.Width, f.Height)
+	return row
+}
+
+//Luma Returns the full range luma plane of a frame from a YUV source, or nil if the frame is RGB.
+//It is the BT.601 luma equations.RGBtoBrightness takes from the RGB pixels, so both give the same brightness
+func (f *Frame) Luma() []byte {
+	if f.yuv == nil {
+		return nil
+	}
+
+	if f.yuv.FullRange {
+		return f.yuv.Y
+	}
+
+	luma := make([]byte, len(f.yuv.Y))
+	for i, y := range f.yuv.Y {
+		luma[i] = limitedLumaLookup[y]
+	}
+	return luma
+}
+
+//getRGB converts the pixel at x,y to RGB using BT.601 coefficients
+func (p *yuvPlanes) getRGB(x, y, width, height int) Pixel {
+	luma := p.Y[y*width+x]
+	cb, cr := byte(128), byte(128)
+
+	if p.Cb != nil {
+		chromaPosition := (y*p.ChromaHeight/height)*p.ChromaWidth + x*p.ChromaWidth/width
+		cb = p.Cb[chromaPosition]
+		cr = p.Cr[chromaPosition]
+	}
+
+	if !p.FullRange {
+		luma = limitedLumaLookup[luma]
+		cb = limitedChromaLookup[cb]
+		cr = limitedChromaLookup[cr]
+	}
+
+	r, g, b := color.YCbCrToRGB(luma, cb, cr)
+	return Pixel{int(r), int(g), int(b)}
+}
+
+//fillRow converts row y to packed rgb24 pixels in row
+func (p *yuvPlanes) fillRow(row []byte, y, width, height int) {
+	for x := 0; x < width; x++ {
+		pixel := p.getRGB(x, y, width, height)
+		row[x*3], row[x*3+1], row[x*3+2] = byte(pixel.Red), byte(pixel.Green), byte(pixel.Blue)
+	}
+}
+
+//createRangeLookup creates a table that stretches values from [low, high] to [0, 255]
+func createRangeLookup(low, high int) [256]byte {
+	var lookup [256]byte
+	for i := range lookup {
+		value := int(math.Round(float64(i-low) * 255 / float64(high-low)))
+		if value < 0 {
+			value = 0
+		} else if value > 255 {
+			value = 255
+		}
+		lookup[i] = byte(value)
+	}
+	return lookup
+}
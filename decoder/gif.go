@@ -0,0 +1,250 @@
+package decoder
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"time"
+)
+
+//Browsers promote delays of 0 and 1 hundredths of a second to this delay
+const _GIFMinimumDelay = 2
+const _GIFPromotedDelay = 10
+
+//How many times a GIF that loops forever is played by default
+const _GIFDefaultInfiniteLoopPlays = 2
+
+//GIFSource Frame source that plays back animated GIFs on a constant frame rate timeline without ffmpeg
+type GIFSource struct {
+	FileName                string
+	FrameWidth, FrameHeight int
+	FramesPerSecond         int //Chosen so that every GIF frame delay and one second are a whole number of frames
+	LoopCount               int //Loop count from the GIF, 0 loops forever, -1 plays once, otherwise plays LoopCount+1 times
+	InfiniteLoopPlays       int //How many times to play a GIF that loops forever
+	opened                  bool
+	started                 bool //Whether Start ever succeeded, frames before it are an error rather than the end
+	reader                  io.Reader
+	closer                  io.Closer
+	composites              [][]byte //rgb24 pixels of every GIF frame after compositing
+	repeats                 []int    //How many timeline frames every GIF frame is shown for
+	plays                   int
+	gifFrame                int
+	repeat                  int
+	play                    int
+	frameIndex              uint
+}
+
+//NewGIFSource Creates a new animated GIF frame source for the given file
+func NewGIFSource(fileName string) GIFSource {
+	var source GIFSource
+	source.FileName = fileName
+	source.InfiniteLoopPlays = _GIFDefaultInfiniteLoopPlays
+	return source
+}
+
+//NewGIFSourceFromReader Creates a new animated GIF frame source reading from stream, name is used for reports
+func NewGIFSourceFromReader(name string, stream io.Reader) GIFSource {
+	source := NewGIFSource(name)
+	source.reader = stream
+	return source
+}
+
+//Start decodes and composites the GIF and builds its timeline
+func (s *GIFSource) Start() error {
+
+	if s.opened {
+		return errors.New("GIF source has already been started")
+	}
+
+	if s.reader == nil {
+		file, err := os.Open(s.FileName)
+		if err != nil {
+			return err
+		}
+		s.closer = file
+		s.reader = file
+	}
+
+	animation, err := gif.DecodeAll(s.reader)
+	if err != nil {
+		return err
+	}
+
+	if len(animation.Image) == 0 {
+		return errors.New("GIF has no frames")
+	}
+
+	s.FrameWidth = animation.Config.Width
+	s.FrameHeight = animation.Config.Height
+	if s.FrameWidth == 0 || s.FrameHeight == 0 {
+		bounds := animation.Image[0].Bounds()
+		s.FrameWidth = bounds.Max.X
+		s.FrameHeight = bounds.Max.Y
+	}
+
+	s.composites = compositeGIF(animation, s.FrameWidth, s.FrameHeight)
+	s.createTimeline(animation.Delay)
+
+	s.LoopCount = animation.LoopCount
+	switch {
+	case s.LoopCount == 0:
+		s.plays = s.InfiniteLoopPlays
+	case s.LoopCount < 0:
+		s.plays = 1
+	default:
+		s.plays = s.LoopCount + 1
+	}
+
+	s.opened = true
+	s.started = true
+	return nil
+}
+
+//Close Closes the underlying file
+func (s *GIFSource) Close() {
+	s.opened = false
+	if s.closer != nil {
+		s.closer.Close()
+	}
+}
+
+//Dimensions returns the width and height of the GIF's logical screen
+func (s *GIFSource) Dimensions() (int, int) {
+	return s.FrameWidth, s.FrameHeight
+}
+
+//FrameRate returns the frames per second of the constant rate timeline
+func (s *GIFSource) FrameRate() int {
+	return s.FramesPerSecond
+}
+
+//Name returns the file being read
+func (s *GIFSource) Name() string {
+	return s.FileName
+}
+
+//Length returns how many frames are on the timeline over every play and how long they play for
+func (s *GIFSource) Length() (int, time.Duration) {
+	frames := 0
+	for _, repeat := range s.repeats {
+		frames += repeat
+	}
+	frames *= s.plays
+	return frames, frameTimestamp(uint(frames), s.FramesPerSecond)
+}
+
+//NextFrame returns the next frame on the constant rate timeline
+func (s *GIFSource) NextFrame() (Frame, error) {
+	var frame Frame
+
+	if !s.started {
+		return frame, ErrNotStarted
+	}
+	if !s.opened || s.play >= s.plays {
+		return frame, io.EOF
+	}
+
+	frame.pixels = s.composites[s.gifFrame]
+	frame.Width = s.FrameWidth
+	frame.Height = s.FrameHeight
+	frame.Index = s.frameIndex
+	frame.Timestamp = frameTimestamp(s.frameIndex, s.FramesPerSecond)
+	s.frameIndex++
+
+	//Advance along the timeline
+	s.repeat++
+	if s.repeat >= s.repeats[s.gifFrame] {
+		s.repeat = 0
+		s.gifFrame++
+	}
+	if s.gifFrame >= len(s.composites) {
+		s.gifFrame = 0
+		s.play++
+	}
+
+	return frame, nil
+}
+
+//createTimeline chooses the frame rate from the greatest common divisor of the delays and one second
+//so every GIF frame is shown for a whole number of frames and the rate is a whole number of frames per second
+func (s *GIFSource) createTimeline(delays []int) {
+	effectiveDelays := make([]int, len(s.composites))
+
+	//Delays are in hundredths of a second
+	divisor := 100
+
+	for i := range effectiveDelays {
+		delay := 0
+		if i < len(delays) {
+			delay = delays[i]
+		}
+		if delay < _GIFMinimumDelay {
+			delay = _GIFPromotedDelay
+		}
+		effectiveDelays[i] = delay
+		divisor = greatestCommonDivisor(divisor, delay)
+	}
+
+	s.FramesPerSecond = 100 / divisor
+	s.repeats = make([]int, len(effectiveDelays))
+	for i, delay := range effectiveDelays {
+		s.repeats[i] = delay / divisor
+	}
+}
+
+//compositeGIF draws every GIF frame over the previous ones, honoring the disposal methods
+func compositeGIF(animation *gif.GIF, width, height int) [][]byte {
+	composites := make([][]byte, len(animation.Image))
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for i, paletted := range animation.Image {
+		var disposal byte
+		if i < len(animation.Disposal) {
+			disposal = animation.Disposal[i]
+		}
+
+		var restore *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			restore = image.NewRGBA(canvas.Bounds())
+			copy(restore.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, paletted.Bounds(), paletted, paletted.Bounds().Min, draw.Over)
+		composites[i] = rGBAToRGB24(canvas)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, paletted.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = restore
+		}
+	}
+
+	return composites
+}
+
+//rGBAToRGB24 packs an image into rgb24 pixels, transparent areas become black
+func rGBAToRGB24(img *image.RGBA) []byte {
+	bounds := img.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+		for x := 0; x < len(row); x += 4 {
+			pixels = append(pixels, row[x], row[x+1], row[x+2])
+		}
+	}
+
+	return pixels
+}
+
+//greatestCommonDivisor finds the greatest common divisor of a and b
+func greatestCommonDivisor(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,72 @@
+package decoder
+
+import (
+	"io"
+	"time"
+)
+
+//MemorySource Frame source that plays back frames held in memory
+type MemorySource struct {
+	SourceName      string
+	FramesPerSecond int
+	frames          []Frame
+	position        int
+}
+
+//NewMemorySource Creates a frame source that plays back frames at fps
+func NewMemorySource(name string, frames []Frame, fps int) MemorySource {
+	var source MemorySource
+	source.SourceName = name
+	source.FramesPerSecond = fps
+	source.frames = frames
+	return source
+}
+
+//NextFrame gets the next frame in memory
+func (m *MemorySource) NextFrame() (Frame, error) {
+	if m.position >= len(m.frames) {
+		return Frame{}, io.EOF
+	}
+
+	frame := m.frames[m.position]
+	frame.Index = uint(m.position)
+	frame.Timestamp = frameTimestamp(frame.Index, m.FramesPerSecond)
+	m.position++
+	return frame, nil
+}
+
+//Length returns how many frames are held and how long they play for
+func (m *MemorySource) Length() (int, time.Duration) {
+	return len(m.frames), frameTimestamp(uint(len(m.frames)), m.FramesPerSecond)
+}
+
+//Close stops playback, no more frames will be produced
+func (m *MemorySource) Close() {
+	m.position = len(m.frames)
+}
+
+//Dimensions returns the width and height of the first frame
+func (m *MemorySource) Dimensions() (int, int) {
+	if len(m.frames) == 0 {
+		return 0, 0
+	}
+	return m.frames[0].Width, m.frames[0].Height
+}
+
+//FrameRate returns the frames per second frames are played back at
+func (m *MemorySource) FrameRate() int {
+	return m.FramesPerSecond
+}
+
+//Name returns the name given to the source
+func (m *MemorySource) Name() string {
+	return m.SourceName
+}
+
+//frameTimestamp calculates the presentation time of the frame at index in a constant frame rate stream
+func frameTimestamp(index uint, fps int) time.Duration {
+	if fps <= 0 {
+		return 0
+	}
+	return time.Duration(index) * time.Second / time.Duration(fps)
+}
@@ -0,0 +1,48 @@
+package decoder
+
+import (
+	"context"
+	"time"
+)
+
+//FrameSource Provides frames to the processors, the ffmpeg Decoder is one implementation
+type FrameSource interface {
+	//NextFrame returns the next frame stamped with its Index and Timestamp, io.EOF when there are no frames left,
+	//or an error such as ErrTruncated or ErrDecodeFailed when the video could not be read to the end
+	NextFrame() (Frame, error)
+	//Close stops the source from producing frames
+	Close()
+	//Dimensions returns the width and height of every frame
+	Dimensions() (int, int)
+	//FrameRate returns the nominal frames per second
+	FrameRate() int
+	//Name identifies the source, it is used to name exported reports
+	Name() string
+}
+
+//ContextFrameSource is a FrameSource that can stop waiting for a frame when a context is done
+type ContextFrameSource interface {
+	FrameSource
+	//NextFrameContext is NextFrame, but returns ctx.Err() if ctx is done before a frame is ready
+	NextFrameContext(ctx context.Context) (Frame, error)
+}
+
+//LengthFrameSource is a FrameSource that knows how long the video is before it has been read
+type LengthFrameSource interface {
+	FrameSource
+	//Length returns how many frames the video has and how long it plays for, either is 0 when unknown
+	Length() (int, time.Duration)
+}
+
+//NextFrameContext gets the next frame from source, returning ctx.Err() once ctx is done.
+//Sources that can't be interrupted are checked before every frame
+func NextFrameContext(ctx context.Context, source FrameSource) (Frame, error) {
+	if contextSource, ok := source.(ContextFrameSource); ok {
+		return contextSource.NextFrameContext(ctx)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Frame{}, err
+	}
+	return source.NextFrame()
+}
@@ -0,0 +1,300 @@
+package decoder
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const _Y4MSignature = "YUV4MPEG2"
+const _Y4MFrameSignature = "FRAME"
+const _Y4MDefaultColorspace = "420jpeg"
+
+//Y4MSource Frame source that reads uncompressed YUV4MPEG2 video without ffmpeg
+type Y4MSource struct {
+	FileName                string
+	FrameWidth, FrameHeight int
+	FramesPerSecond         int
+	FrameRateNumerator      int
+	FrameRateDenominator    int
+	Interlacing             string //p progressive, t top field first, b bottom field first, m mixed, ? unknown
+	Colorspace              string //Chroma subsampling, such as 420jpeg, 422, 444 or mono
+	FullRange               bool   //Whether samples use the full 0-255 range instead of the limited 16-235 range
+	opened                  bool
+	started                 bool //Whether Start ever succeeded, frames before it are an error rather than the end
+	reader                  *bufio.Reader
+	closer                  io.Closer
+	chromaWidth             int
+	chromaHeight            int
+	alphaPlane              bool
+	frameIndex              uint
+}
+
+//NewY4MSource Creates a new YUV4MPEG2 frame source for the given file
+func NewY4MSource(fileName string) Y4MSource {
+	var source Y4MSource
+	source.FileName = fileName
+	return source
+}
+
+//NewY4MSourceFromReader Creates a new YUV4MPEG2 frame source reading from stream, name is used for reports
+func NewY4MSourceFromReader(name string, stream io.Reader) Y4MSource {
+	var source Y4MSource
+	source.FileName = name
+	source.reader = bufio.NewReader(stream)
+	return source
+}
+
+//Start opens the stream and reads the stream header
+func (s *Y4MSource) Start() error {
+
+	if s.opened {
+		return errors.New("Y4M source has already been started")
+	}
+
+	if s.reader == nil {
+		file, err := os.Open(s.FileName)
+		if err != nil {
+			return err
+		}
+		s.closer = file
+		s.reader = bufio.NewReader(file)
+	}
+
+	header, err := s.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	err = s.parseHeader(strings.TrimSuffix(header, "\n"))
+	if err != nil {
+		return err
+	}
+
+	s.opened = true
+	s.started = true
+	return nil
+}
+
+//Close Closes the underlying file
+func (s *Y4MSource) Close() {
+	s.opened = false
+	if s.closer != nil {
+		s.closer.Close()
+	}
+}
+
+//Dimensions returns the width and height of the frames
+func (s *Y4MSource) Dimensions() (int, int) {
+	return s.FrameWidth, s.FrameHeight
+}
+
+//FrameRate returns the frames per second rounded to the nearest frame
+func (s *Y4MSource) FrameRate() int {
+	return s.FramesPerSecond
+}
+
+//Name returns the file being read
+func (s *Y4MSource) Name() string {
+	return s.FileName
+}
+
+//NextFrame reads the next frame, frames keep their YUV planes so the luma can be read directly
+func (s *Y4MSource) NextFrame() (Frame, error) {
+	var frame Frame
+
+	if !s.started {
+		return frame, ErrNotStarted
+	}
+	if !s.opened {
+		return frame, io.EOF
+	}
+
+	frameHeader, err := s.reader.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && frameHeader == "" {
+			return frame, io.EOF
+		}
+		if err == io.EOF {
+			return frame, ErrTruncated
+		}
+		return frame, err
+	}
+
+	if !strings.HasPrefix(frameHeader, _Y4MFrameSignature) {
+		return frame, errors.New("Invalid Y4M frame header")
+	}
+
+	var planes yuvPlanes
+	planes.FullRange = s.FullRange
+	planes.ChromaWidth = s.chromaWidth
+	planes.ChromaHeight = s.chromaHeight
+
+	planes.Y, err = s.readPlane(s.FrameWidth * s.FrameHeight)
+	if err != nil {
+		return frame, err
+	}
+
+	if s.chromaWidth > 0 {
+		planes.Cb, err = s.readPlane(s.chromaWidth * s.chromaHeight)
+		if err != nil {
+			return frame, err
+		}
+		planes.Cr, err = s.readPlane(s.chromaWidth * s.chromaHeight)
+		if err != nil {
+			return frame, err
+		}
+	}
+
+	//Alpha does not affect what the viewer sees, skip it
+	if s.alphaPlane {
+		_, err = s.reader.Discard(s.FrameWidth * s.FrameHeight)
+		if err == io.EOF {
+			err = ErrTruncated
+		}
+		if err != nil {
+			return frame, err
+		}
+	}
+
+	frame.yuv = &planes
+	frame.Width = s.FrameWidth
+	frame.Height = s.FrameHeight
+	frame.Index = s.frameIndex
+	frame.Timestamp = s.frameTimestamp(s.frameIndex)
+	s.frameIndex++
+	return frame, nil
+}
+
+//readPlane reads a single plane of size bytes
+func (s *Y4MSource) readPlane(size int) ([]byte, error) {
+	plane := make([]byte, size, size)
+	_, err := io.ReadFull(s.reader, plane)
+
+	//The frame header promised a plane, so running out here is never a clean end of stream
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrTruncated
+	}
+	return plane, err
+}
+
+//frameTimestamp calculates the exact presentation time of the frame at index from the header frame rate
+func (s *Y4MSource) frameTimestamp(index uint) time.Duration {
+	seconds := float64(index) * float64(s.FrameRateDenominator) / float64(s.FrameRateNumerator)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+//parseHeader reads the stream parameters from the YUV4MPEG2 stream header
+func (s *Y4MSource) parseHeader(header string) error {
+	fields := strings.Split(header, " ")
+
+	if fields[0] != _Y4MSignature {
+		return errors.New("Not a YUV4MPEG2 stream")
+	}
+
+	s.Interlacing = "?"
+	s.Colorspace = _Y4MDefaultColorspace
+
+	for _, field := range fields[1:] {
+		if field == "" {
+			continue
+		}
+
+		value := field[1:]
+
+		switch field[0] {
+		case 'W':
+			width, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			s.FrameWidth = width
+		case 'H':
+			height, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			s.FrameHeight = height
+		case 'F':
+			numerator, denominator, err := parseY4MRatio(value)
+			if err != nil {
+				return err
+			}
+			s.FrameRateNumerator = numerator
+			s.FrameRateDenominator = denominator
+		case 'I':
+			s.Interlacing = value
+		case 'C':
+			s.Colorspace = value
+		case 'X':
+			if strings.HasPrefix(value, "COLORRANGE=") {
+				s.FullRange = strings.TrimPrefix(value, "COLORRANGE=") == "FULL"
+			}
+		}
+	}
+
+	if s.FrameWidth <= 0 || s.FrameHeight <= 0 {
+		return errors.New("Y4M header is missing the frame dimensions")
+	}
+
+	if s.FrameRateNumerator <= 0 || s.FrameRateDenominator <= 0 {
+		return errors.New("Y4M header is missing the frame rate")
+	}
+
+	s.FramesPerSecond = int(math.Round(float64(s.FrameRateNumerator) / float64(s.FrameRateDenominator)))
+
+	return s.calculateChromaSize()
+}
+
+//calculateChromaSize finds the size of the chroma planes from the colorspace
+func (s *Y4MSource) calculateChromaSize() error {
+	switch s.Colorspace {
+	case "420jpeg", "420paldv", "420mpeg2", "420":
+		s.chromaWidth = (s.FrameWidth + 1) / 2
+		s.chromaHeight = (s.FrameHeight + 1) / 2
+	case "422":
+		s.chromaWidth = (s.FrameWidth + 1) / 2
+		s.chromaHeight = s.FrameHeight
+	case "411":
+		s.chromaWidth = (s.FrameWidth + 3) / 4
+		s.chromaHeight = s.FrameHeight
+	case "444":
+		s.chromaWidth = s.FrameWidth
+		s.chromaHeight = s.FrameHeight
+	case "444alpha":
+		s.chromaWidth = s.FrameWidth
+		s.chromaHeight = s.FrameHeight
+		s.alphaPlane = true
+	case "mono":
+		s.chromaWidth = 0
+		s.chromaHeight = 0
+	default:
+		return errors.New("Unsupported Y4M colorspace " + s.Colorspace)
+	}
+	return nil
+}
+
+//parseY4MRatio parses a ratio such as 30000:1001
+func parseY4MRatio(ratio string) (int, int, error) {
+	operands := strings.Split(ratio, ":")
+	if len(operands) != 2 {
+		return 0, 0, errors.New("Invalid Y4M ratio " + ratio)
+	}
+
+	numerator, err := strconv.Atoi(operands[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	denominator, err := strconv.Atoi(operands[1])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return numerator, denominator, nil
+}
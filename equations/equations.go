@@ -10,23 +10,98 @@ const PercentageFlashArea float32 = 0.25
 //FlashFrequencyMax ITU-R: maximum flash frequency = 3Hz
 const FlashFrequencyMax int = 3
 
+//ExtendedFlashSecondsMax Ofcom: flashing may not continue for more than 5 seconds
+const ExtendedFlashSecondsMax float32 = 5
+
 //FlashDeltaMax ITU-R: Delta Candellas must be >= 20cd/m^2
 const FlashDeltaMax float32 = 20
 
-//rgbBrightnessLookup optimization table for calculating brightness from luminance
-var rgbBrightnessLookup = map[int]int{}
+//DarkBrightnessMax ITU-R: the darker image of a flash or pattern must be below 160cd/m^2
+const DarkBrightnessMax float32 = 160
+
+//PatternPairsMax ITU-R: a regular pattern may have at most 5 light-dark pairs of stripes
+const PatternPairsMax int = 5
+
+//SaturatedRedRatio ITU-R: a color is saturated red when R/(R+G+B) >= 0.8
+const SaturatedRedRatio float32 = 0.8
+
+//RedChromaticityDeltaMin ITU-R: a red transition must change CIE 1976 UCS chromaticity by more than 0.2
+const RedChromaticityDeltaMin float32 = 0.2
+
+//whitePointU, whitePointV D65 chromaticity, used for pixels with no light
+const whitePointU, whitePointV float32 = 0.1978, 0.4683
+
+//srgbLinearLookup converts an 8 bit sRGB component to linear light
+var srgbLinearLookup = createSRGBLinearLookup()
+
+//brightnessLookup converts a full range 8 bit luma value to brightness, it is filled before anything can read it
+//so any number of analyses can share it
+var brightnessLookup = createBrightnessLookup()
 
-//RGBtoBrightness coverts RGB values to brightness values
+//RGBtoBrightness coverts RGB values to brightness values.
+//Luma uses the BT.601 weights, the same ffmpeg and the decoder convert YUV to RGB with, so it is the Y plane a YUV frame had
 func RGBtoBrightness(R, G, B int) int {
-	//First convert to luminance
-	y := int(0.2126*float64(R) + 0.7152*float64(G) + 0.0722*float64(B))
-
-	//Did we already calculate gamma before hand?
-	if gamma, ok := rgbBrightnessLookup[y]; ok {
-		return gamma
-	} else { //Calculate and store
-		gamma = int(413.435 * math.Pow((0.002745*float64(y)+0.0189623), 2.2))
-		rgbBrightnessLookup[y] = gamma
-		return gamma
+	//First convert to luma
+	y := int(0.299*float64(R) + 0.587*float64(G) + 0.114*float64(B) + 0.5)
+
+	return LumaToBrightness(y)
+}
+
+//LumaToBrightness converts a full range 8 bit BT.601 luma value, such as the Y plane of a YUV frame, to brightness
+func LumaToBrightness(y int) int {
+	if y < 0 {
+		y = 0
+	} else if y > 255 {
+		y = 255
+	}
+	return brightnessLookup[y]
+}
+
+//RGBtoRedRatio computes the proportion of red in a color, R/(R+G+B)
+func RGBtoRedRatio(R, G, B int) float32 {
+	sum := R + G + B
+	if sum == 0 {
+		return 0
+	}
+	return float32(R) / float32(sum)
+}
+
+//RGBtoChromaticity converts RGB values to CIE 1976 UCS (u', v') chromaticity coordinates
+func RGBtoChromaticity(R, G, B int) (float32, float32) {
+	r, g, b := srgbLinearLookup[R], srgbLinearLookup[G], srgbLinearLookup[B]
+
+	//sRGB to CIE XYZ (D65)
+	x := 0.4124*r + 0.3576*g + 0.1805*b
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := 0.0193*r + 0.1192*g + 0.9505*b
+
+	denominator := x + 15*y + 3*z
+	if denominator == 0 {
+		return whitePointU, whitePointV
+	}
+
+	return float32(4 * x / denominator), float32(9 * y / denominator)
+}
+
+//createBrightnessLookup calculates the brightness of every luma value
+func createBrightnessLookup() [256]int {
+	var lookup [256]int
+	for y := range lookup {
+		lookup[y] = int(413.435 * math.Pow((0.002745*float64(y)+0.0189623), 2.2))
+	}
+	return lookup
+}
+
+//createSRGBLinearLookup precomputes the sRGB transfer function for every 8 bit component value
+func createSRGBLinearLookup() [256]float64 {
+	var lookup [256]float64
+	for i := range lookup {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			lookup[i] = c / 12.92
+		} else {
+			lookup[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
 	}
+	return lookup
 }
@@ -4,15 +4,29 @@ import (
 	"bytes"
 	"container/list"
 	"encoding/json"
+	"fmt"
+	"math"
 	"time"
 )
 
+//Types of hazards
+const (
+	FlashHazard         = "Flash"         //More than 3 flashes in any one second period
+	ExtendedFlashHazard = "ExtendedFlash" //Flashing that continues for more than 5 seconds
+	RedFlashHazard      = "RedFlash"      //More than 3 saturated red flashes in any one second period
+	PatternHazard       = "Pattern"       //A regular pattern with too many light-dark pairs over too much of the screen
+)
+
+//SeverityHigh the severity of a hazard that fails the compliance profile
+const SeverityHigh = "high"
+
 //HazardList a list of hazards
 type HazardList = list.List
 
 //HazardReport collection of hazards found during processing
 type HazardReport struct {
 	CreatedOn time.Time
+	Profile   string //Name of the compliance profile that produced the report
 	Hazards   HazardList
 }
 
@@ -31,6 +45,16 @@ func (hr *HazardReport) MarshalJSON() ([]byte, error) {
 	buf.WriteString("\"createdOn\":")
 	buf.Write(m)
 	buf.WriteByte(',')
+
+	m, err = json.Marshal(hr.Profile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString("\"profile\":")
+	buf.Write(m)
+	buf.WriteByte(',')
 	buf.WriteString("\"hazards\":[")
 	once := false
 	for ele := hr.Hazards.Front(); ele != nil; ele = ele.Next() {
@@ -56,7 +80,71 @@ func (hr *HazardReport) MarshalJSON() ([]byte, error) {
 
 //Hazard describes hazardous content that is found in a video
 type Hazard struct {
-	Start      uint   `json:"start"`
-	End        uint   `json:"end"`
-	HazardType string `json:"hazardType"`
+	Start         uint     `json:"start"`         //Whole second the hazard starts in
+	End           uint     `json:"end"`           //Whole second the hazard ends in
+	StartFrame    uint     `json:"startFrame"`    //Index of the first offending frame
+	EndFrame      uint     `json:"endFrame"`      //Index of the last offending frame
+	StartTime     float64  `json:"startTime"`     //Seconds when the first offending frame is shown
+	EndTime       float64  `json:"endTime"`       //Seconds when the last offending frame stops being shown
+	StartTimecode string   `json:"startTimecode"` //SMPTE timecode of StartTime
+	EndTimecode   string   `json:"endTimecode"`   //SMPTE timecode of EndTime
+	Duration      float64  `json:"duration"`      //Seconds the hazard lasts
+	HazardType    string   `json:"hazardType"`
+	Regions       []Region `json:"regions,omitempty"`      //Bounding boxes of the areas of the screen taking part in the hazard
+	Heatmap       string   `json:"heatmap,omitempty"`      //Path to a PNG showing how often each area took part in the hazard
+	Evidence      []string `json:"evidence,omitempty"`     //Paths to PNGs of the frames either side of the biggest change in the picture during the hazard
+	ContactSheet  string   `json:"contactSheet,omitempty"` //Path to a PNG of the frames around that change in a grid, labelled with their timecodes
+}
+
+//Region is a rectangle of the screen in the pixels of the analyzed frames
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+//NewHazard creates a hazard spanning the frames startFrame to endFrame,
+//start is when startFrame is shown and end is when endFrame stops being shown
+func NewHazard(hazardType string, startFrame, endFrame uint, start, end time.Duration, fps int) Hazard {
+	var hazard Hazard
+	hazard.HazardType = hazardType
+	hazard.StartFrame = startFrame
+	hazard.Start = uint(start / time.Second)
+	hazard.StartTime = start.Seconds()
+	hazard.StartTimecode = Timecode(start, fps)
+	hazard.EndFrame = endFrame
+	hazard.End = uint(end / time.Second)
+	hazard.EndTime = end.Seconds()
+	hazard.EndTimecode = Timecode(end, fps)
+	hazard.Duration = hazard.EndTime - hazard.StartTime
+	return hazard
+}
+
+//Extend makes the hazard end where other ends
+func (h *Hazard) Extend(other Hazard) {
+	h.End = other.End
+	h.EndFrame = other.EndFrame
+	h.EndTime = other.EndTime
+	h.EndTimecode = other.EndTimecode
+	h.Duration = h.EndTime - h.StartTime
+}
+
+//Severity rates the hazard. Every type of hazard fails the compliance profile, extended flashing and patterns
+//as much as flashing over the frequency limit, so every hazard is high
+func (h *Hazard) Severity() string {
+	return SeverityHigh
+}
+
+//Timecode formats a presentation time as a non drop frame SMPTE timecode, HH:MM:SS:FF
+func Timecode(t time.Duration, fps int) string {
+	if fps <= 0 {
+		fps = 1
+	}
+
+	totalFrames := int64(math.Round(t.Seconds() * float64(fps)))
+	frames := totalFrames % int64(fps)
+	totalSeconds := totalFrames / int64(fps)
+
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", totalSeconds/3600, totalSeconds/60%60, totalSeconds%60, frames)
 }
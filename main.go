@@ -1,50 +1,158 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/lycerius/epilguard/decoder"
 	"github.com/lycerius/epilguard/processors"
+	"github.com/lycerius/epilguard/profiles"
 )
 
 var reportDirectory string
 var videoFile string
 var frameBufferLength uint
+var detectRedFlashes bool
+var detectPatterns bool
+var profileName string
+var profile profiles.Profile
+var exportHeatmaps bool
+var exportEvidence bool
+var exportHTML bool
+var exportCues bool
+var workers int
+var timeout time.Duration
+var progressFormat string
+
+//The video file that means stdin, and what reports of it are named after
+const stdinInput = "-"
+const stdinName = "stdin"
 
 //main Main entry point
 func main() {
+	//Live streams are watched by their own command
+	if len(os.Args) > 1 && os.Args[1] == monitorCommand {
+		runMonitor(os.Args[2:])
+		return
+	}
+
 	processArguments()
 
-	//Video must exist at path
-	if _, err := os.Stat(videoFile); err != nil {
-		log.Fatal("Could not open '", videoFile, "', ", err)
+	//Video must exist at path, unless it is piped in
+	if videoFile != stdinInput {
+		if _, err := os.Stat(videoFile); err != nil {
+			log.Fatal("Could not open '", videoFile, "', ", err)
+		}
+	} else if exportEvidence {
+		log.Fatal("-evidence reads the video twice, stdin can only be read once")
 	}
 
-	//Create decoder
-	decoder := decoder.NewDecoder(videoFile)
-	decoder.FrameBufferCacheSize = int(frameBufferLength)
-	decoder.Start()
+	//Ctrl-C or running out of time stops the analysis and ffmpeg
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-	//Attatch to new processor
-	processor := processors.NewFlashingProcessor(&decoder, reportDirectory)
+	//Every analysis runs on the same frames, so the video is only decoded once
+	source, err := openSource(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	//Look for hazards
-	err := processor.Process()
+	err = runAnalysis(ctx, source, createProgressFunc(progressFormat, "flash", os.Stderr, os.Stdout))
+	source.Close()
 
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+//runAnalysis looks for flashing, and saturated red flashing and regular patterns in the same frames
+func runAnalysis(ctx context.Context, source decoder.FrameSource, progress processors.ProgressFunc) error {
+	processor := processors.NewFlashingProcessor(source, reportDirectory)
+	processor.Profile = profile
+	processor.RedFlashes = detectRedFlashes
+	processor.Patterns = detectPatterns
+	processor.Heatmaps = exportHeatmaps
+	processor.HTMLReport = exportHTML
+	processor.Cues = exportCues
+	if exportEvidence {
+		processor.Evidence = openSource
+	}
+	processor.Workers = workers
+	processor.Progress = progress
+	return processor.ProcessContext(ctx)
+}
+
+//openSource creates and starts a frame source for videoFile, YUV4MPEG2 files and GIFs are read without ffmpeg
+//and - is read from stdin. It also reopens the video for the frames of the hazards found in it
+func openSource(ctx context.Context) (decoder.FrameSource, error) {
+	if videoFile == stdinInput {
+		source := decoder.NewDecoderFromReader(stdinName, os.Stdin)
+		source.FrameBufferCacheSize = int(frameBufferLength)
+		if err := source.StartContext(ctx); err != nil {
+			return nil, err
+		}
+		return &source, nil
+	}
+
+	extension := filepath.Ext(videoFile)
+
+	if strings.EqualFold(extension, ".y4m") {
+		source := decoder.NewY4MSource(videoFile)
+		if err := source.Start(); err != nil {
+			return nil, err
+		}
+		return &source, nil
+	}
+
+	if strings.EqualFold(extension, ".gif") {
+		source := decoder.NewGIFSource(videoFile)
+		if err := source.Start(); err != nil {
+			return nil, err
+		}
+		return &source, nil
+	}
+
+	source := decoder.NewDecoder(videoFile)
+	source.FrameBufferCacheSize = int(frameBufferLength)
+	if err := source.StartContext(ctx); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
 func processArguments() {
 	flag.StringVar(&reportDirectory, "report-dir", "", "directory to write report files to (default $cwd)")
 	flag.UintVar(&frameBufferLength, "buffer-size", 30, "Sets the size of the lookahead framebuffer, must be > 0")
+	flag.BoolVar(&detectRedFlashes, "red-flash", true, "Detect saturated red flashes")
+	flag.BoolVar(&detectPatterns, "pattern", true, "Detect regular patterns such as stripes and checkerboards")
+	flag.BoolVar(&exportHeatmaps, "heatmaps", false, "Export a heatmap PNG for every hazard showing where on screen it is")
+	flag.BoolVar(&exportCues, "cues", false, "Export the hazards as WebVTT and SRT cue tracks, one cue per hazard, for players to warn viewers or skip ahead")
+	flag.BoolVar(&exportHTML, "html", false, "Also export the report as a self contained HTML page with charts, embedding the evidence of -evidence")
+	flag.BoolVar(&exportEvidence, "evidence", false, "Export PNGs of the frames where every hazard changes the most and a contact sheet of the frames around them, the video is read twice")
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "How many frames to analyze at once")
+	flag.DurationVar(&timeout, "timeout", 0, "Give up on the analysis after this long, such as 90m (default no limit)")
+	flag.StringVar(&progressFormat, "progress", progressBar, "How to show progress: bar on stderr, json lines on stdout, or none")
+	flag.StringVar(&profileName, "profile", profiles.Default().Name, "Compliance profile, one of "+builtInProfileNames()+", or a path to a JSON profile")
 
 	flag.Usage = func() {
 		fmt.Println("epilguard [options] video")
+		fmt.Println("epilguard monitor [options] input")
+		fmt.Println()
+		fmt.Println("video is a file, or - to read it from stdin")
 		fmt.Println()
 		flag.PrintDefaults()
 	}
@@ -66,11 +174,25 @@ func processArguments() {
 		reportDirectory = rDir
 	}
 
-	if frameBufferLength <= 0 {
+	if frameBufferLength <= 0 || !validProgressFormat(progressFormat) {
 		flag.Usage()
 		os.Exit(1)
 	}
 
 	videoFile = flag.Arg(0)
 
+	var err error
+	profile, err = profiles.Find(profileName)
+	if err != nil {
+		log.Fatal("Could not load profile '", profileName, "', ", err)
+	}
+}
+
+//builtInProfileNames lists the names of the built in profiles for the usage text
+func builtInProfileNames() string {
+	names := make([]string, len(profiles.BuiltIn))
+	for i, builtIn := range profiles.BuiltIn {
+		names[i] = builtIn.Name
+	}
+	return strings.Join(names, ", ")
 }
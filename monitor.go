@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lycerius/epilguard/decoder"
+	"github.com/lycerius/epilguard/hazards"
+	"github.com/lycerius/epilguard/processors"
+	"github.com/lycerius/epilguard/profiles"
+)
+
+//The command that watches a live stream
+const monitorCommand = "monitor"
+
+//Trade offs between how soon an alert is raised and how sure it is
+const (
+	latencyLow      = "low"
+	latencyAccurate = "accurate"
+)
+
+//Kinds of monitor events
+const (
+	eventAlert  = "alert"
+	eventHazard = "hazard"
+)
+
+//How long a webhook has to accept an event
+const _WebhookTimeout = 5 * time.Second
+
+//How many events can wait to be sent before the analysis waits for them
+const _MonitorEventBuffer = 64
+
+//How many events can wait for a slow webhook or socket before new events are dropped
+const _SinkQueueLength = 256
+
+//monitorEvent is one line of monitor output, or the body of one webhook request
+type monitorEvent struct {
+	Event         string          `json:"event"`                 //alert when flashing becomes hazardous, hazard once the hazard is finished
+	Source        string          `json:"source"`                //Stream being monitored
+	Raised        time.Time       `json:"raised"`                //Wall clock time the event was raised
+	HazardType    string          `json:"hazardType"`            //Flash or ExtendedFlash for alerts, any type of hazard for hazard events
+	StartFrame    uint            `json:"startFrame"`            //First frame of the hazardous flashing
+	StartTime     float64         `json:"startTime"`             //Seconds into the stream StartFrame is shown
+	StartTimecode string          `json:"startTimecode"`         //SMPTE timecode of StartTime
+	Frame         uint            `json:"frame,omitempty"`       //Frame being analyzed when the alert was raised
+	Time          float64         `json:"time,omitempty"`        //Seconds into the stream Frame is shown
+	Flashes       int             `json:"flashes,omitempty"`     //Flashes in the second before the alert
+	Provisional   bool            `json:"provisional,omitempty"` //Whether the alert counted a transition that hadn't finished
+	Hazard        *hazards.Hazard `json:"hazard,omitempty"`      //The finished hazard, for hazard events
+}
+
+//eventSink delivers monitor events
+type eventSink interface {
+	send(ctx context.Context, event monitorEvent) error
+	close() error
+}
+
+//runMonitor watches a live stream for flashing and sends events as soon as it becomes hazardous
+func runMonitor(arguments []string) {
+	flags := flag.NewFlagSet(monitorCommand, flag.ExitOnError)
+	output := flags.String("output", "-", "Where to send events: - for JSON lines on stdout, an http(s) URL to POST each event to, or unix:/path/to.sock")
+	latency := flags.String("latency", latencyLow, "low raises alerts as soon as flashing looks hazardous, accurate waits for every transition to finish")
+	realtime := flags.Bool("re", false, "Read the input at its native frame rate, to monitor a file as if it were live")
+	reportDir := flags.String("report-dir", "", "Also write the hazard report and CSVs here once the stream ends (default none)")
+	monitorWorkers := flags.Int("workers", runtime.NumCPU(), "How many frames to analyze at once")
+	monitorRedFlashes := flags.Bool("red-flash", true, "Detect saturated red flashes")
+	monitorPatterns := flags.Bool("pattern", true, "Detect regular patterns such as stripes and checkerboards")
+	monitorProfileName := flags.String("profile", profiles.Default().Name, "Compliance profile, one of "+builtInProfileNames()+", or a path to a JSON profile")
+
+	flags.Usage = func() {
+		fmt.Println("epilguard monitor [options] input")
+		fmt.Println()
+		fmt.Println("input is an ffmpeg URL such as rtmp://, srt:// or udp://, a named pipe, or - for stdin")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() == 0 || (*latency != latencyLow && *latency != latencyAccurate) {
+		flags.Usage()
+		os.Exit(1)
+	}
+	input := flags.Arg(0)
+
+	monitorProfile, err := profiles.Find(*monitorProfileName)
+	if err != nil {
+		log.Fatal("Could not load profile '", *monitorProfileName, "', ", err)
+	}
+
+	//Ctrl-C stops monitoring
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opened, err := openEventSink(*output, os.Stdout)
+	if err != nil {
+		log.Fatal("Could not open '", *output, "', ", err)
+	}
+
+	//A slow webhook or socket mustn't hold up the analysis
+	sink := newQueuedSink(ctx, opened, _SinkQueueLength)
+	defer sink.close()
+
+	low := *latency == latencyLow
+	source := decoder.NewStreamDecoder(input)
+	source.LowDelay = low
+	source.Realtime = *realtime
+	if low {
+		source.FrameBufferCacheSize = 1
+	}
+	if err := source.StartContext(ctx); err != nil {
+		log.Fatal(err)
+	}
+	defer source.Close()
+
+	alerts := make(chan processors.Alert, _MonitorEventBuffer)
+	found := make(chan hazards.Hazard, _MonitorEventBuffer)
+
+	processor := processors.NewFlashingProcessor(&source, *reportDir)
+	processor.Profile = monitorProfile
+	processor.RedFlashes = *monitorRedFlashes
+	processor.Patterns = *monitorPatterns
+	processor.Workers = *monitorWorkers
+	processor.EarlyAlerts = low
+	processor.SkipExport = *reportDir == ""
+	processor.Alerts = alerts
+	processor.Hazards = found
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- processor.ProcessContext(ctx)
+	}()
+
+	fps := source.FrameRate()
+	for {
+		var event monitorEvent
+		select {
+		case alert := <-alerts:
+			event = newAlertEvent(input, alert, fps)
+		case hazard := <-found:
+			event = newHazardEvent(input, hazard)
+		case err := <-finished:
+			//Events raised right before the end are still sent
+			drainMonitorEvents(ctx, sink, input, alerts, found, fps)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				source.Close()
+				sink.close()
+				log.Fatal(err)
+			}
+			return
+		}
+
+		if err := sink.send(ctx, event); err != nil {
+			log.Println("Could not send", event.Event, "event,", err)
+		}
+	}
+}
+
+//drainMonitorEvents sends the events still waiting once the analysis has stopped
+func drainMonitorEvents(ctx context.Context, sink eventSink, input string, alerts <-chan processors.Alert, found <-chan hazards.Hazard, fps int) {
+	for {
+		var event monitorEvent
+		select {
+		case alert := <-alerts:
+			event = newAlertEvent(input, alert, fps)
+		case hazard := <-found:
+			event = newHazardEvent(input, hazard)
+		default:
+			return
+		}
+
+		if err := sink.send(ctx, event); err != nil {
+			log.Println("Could not send", event.Event, "event,", err)
+		}
+	}
+}
+
+//newAlertEvent describes alert, raised while monitoring input
+func newAlertEvent(input string, alert processors.Alert, fps int) monitorEvent {
+	var event monitorEvent
+	event.Event = eventAlert
+	event.Source = input
+	event.Raised = time.Now()
+	event.HazardType = alert.HazardType
+	event.StartFrame = alert.StartFrame
+	event.StartTime = alert.StartTime.Seconds()
+	event.StartTimecode = hazards.Timecode(alert.StartTime, fps)
+	event.Frame = alert.Frame
+	event.Time = alert.Time.Seconds()
+	event.Flashes = alert.Flashes
+	event.Provisional = alert.Provisional
+	return event
+}
+
+//newHazardEvent describes hazard, finished while monitoring input
+func newHazardEvent(input string, hazard hazards.Hazard) monitorEvent {
+	var event monitorEvent
+	event.Event = eventHazard
+	event.Source = input
+	event.Raised = time.Now()
+	event.HazardType = hazard.HazardType
+	event.StartFrame = hazard.StartFrame
+	event.StartTime = hazard.StartTime
+	event.StartTimecode = hazard.StartTimecode
+	event.Hazard = &hazard
+	return event
+}
+
+//openEventSink opens where events are sent: - for stdout, an http(s) URL for a webhook, or unix:path for a local socket
+func openEventSink(output string, stdout io.Writer) (eventSink, error) {
+	switch {
+	case output == "-":
+		return &writerSink{encoder: json.NewEncoder(stdout)}, nil
+	case strings.HasPrefix(output, "http://") || strings.HasPrefix(output, "https://"):
+		return &webhookSink{url: output, client: &http.Client{Timeout: _WebhookTimeout}}, nil
+	case strings.HasPrefix(output, "unix:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(output, "unix:"), "//")
+		sink := &socketSink{path: path}
+		return sink, sink.dial()
+	}
+	return nil, errors.New("Output must be -, an http(s) URL or unix:path")
+}
+
+//queuedSink sends events to another sink from its own goroutine, so the analysis never waits for it.
+//Events that arrive while the queue is full are dropped
+type queuedSink struct {
+	sink   eventSink
+	events chan monitorEvent
+	done   chan struct{}
+}
+
+//newQueuedSink starts sending events to sink, queueing up to length of them
+func newQueuedSink(ctx context.Context, sink eventSink, length int) *queuedSink {
+	queued := &queuedSink{sink: sink, events: make(chan monitorEvent, length), done: make(chan struct{})}
+	go queued.run(ctx)
+	return queued
+}
+
+//run sends the queued events until the queue is closed
+func (s *queuedSink) run(ctx context.Context) {
+	defer close(s.done)
+	for event := range s.events {
+		if err := s.sink.send(ctx, event); err != nil {
+			log.Println("Could not send", event.Event, "event,", err)
+		}
+	}
+}
+
+func (s *queuedSink) send(ctx context.Context, event monitorEvent) error {
+	select {
+	case s.events <- event:
+		return nil
+	default:
+		return errors.New("Too many events are waiting to be sent, the event was dropped")
+	}
+}
+
+//close waits for the queued events to be sent, then closes the sink
+func (s *queuedSink) close() error {
+	close(s.events)
+	<-s.done
+	return s.sink.close()
+}
+
+//writerSink writes every event as a line of JSON
+type writerSink struct {
+	encoder *json.Encoder
+}
+
+func (s *writerSink) send(ctx context.Context, event monitorEvent) error {
+	return s.encoder.Encode(event)
+}
+
+func (s *writerSink) close() error {
+	return nil
+}
+
+//webhookSink POSTs every event as JSON to url
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) send(ctx context.Context, event monitorEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Webhook responded %s", response.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) close() error {
+	return nil
+}
+
+//socketSink writes every event as a line of JSON to a unix socket, reconnecting if the listener went away
+type socketSink struct {
+	path string
+	conn net.Conn
+}
+
+//dial connects to the socket
+func (s *socketSink) dial() error {
+	conn, err := net.Dial("unix", s.path)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *socketSink) send(ctx context.Context, event monitorEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.conn.Write(line); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *socketSink) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
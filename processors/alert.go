@@ -0,0 +1,108 @@
+package processors
+
+import (
+	"time"
+
+	"github.com/lycerius/epilguard/hazards"
+)
+
+//Alert is raised the moment flashing becomes hazardous, long before the hazard it belongs to is finished
+type Alert struct {
+	HazardType  string        //Flash or ExtendedFlash
+	StartFrame  uint          //First frame of the flashing that became hazardous
+	StartTime   time.Duration //When StartFrame is shown
+	Frame       uint          //Frame being analyzed when the alert was raised
+	Time        time.Duration //When Frame is shown
+	Flashes     int           //Flashes in the second before the alert, 0 for ExtendedFlash alerts
+	Provisional bool          //Whether the alert counted a transition that hadn't finished, it may turn out not to be a hazard
+}
+
+//flashAlerter decides when flashing has become hazardous enough to raise an alert, once for each hazard
+type flashAlerter struct {
+	early           bool //Whether the transition in progress is counted
+	alerted         bool //Whether an alert was raised for the flashing starting at alertStart
+	alertStart      uint
+	extendedAlerted bool //Whether an alert was raised for the extended flashing sequence starting at extendedStart
+	extendedStart   uint
+	flashing        *flashingHazardDetector
+	extended        *extendedFlashingDetector
+}
+
+//check returns the alerts for flashing that became hazardous by accumulation, finished are the hazards the frame finished
+//and inProgress is the transition that hasn't finished yet
+func (a *flashAlerter) check(accumulation BrightnessAccumulation, now time.Duration, finished []hazards.Hazard, inProgress flashEvent, hasInProgress bool) []Alert {
+	var alerts []Alert
+
+	//A hazard can be found and finished by the same frame, it still gets an alert
+	for _, hazard := range finished {
+		switch hazard.HazardType {
+		case a.flashing.hazardType:
+			if !a.alerted {
+				alerts = append(alerts, newHazardAlert(hazard, accumulation, a.flashing.hazardFlashes))
+			}
+			a.alerted = false
+		case a.extended.hazardType:
+			if !a.extendedAlerted || a.extendedStart != hazard.StartFrame {
+				alerts = append(alerts, newHazardAlert(hazard, accumulation, 0))
+			}
+			a.extendedAlerted = false
+		}
+	}
+
+	//Flashing that was alerted on has been checked and there is no hazard, so it wasn't one after all
+	if a.alerted && a.flashing.pending() == 0 {
+		if horizon, ok := a.flashing.horizon(); !ok || horizon > a.alertStart {
+			a.alerted = false
+		}
+	}
+
+	//Flashes in the second up to the latest transition are all in one window
+	latest := a.flashing.latest()
+	confirmed := countFlashes(latest)
+
+	events := latest
+	if a.early && hasInProgress {
+		events = eventsBefore(latest, inProgress.Time)
+		events = append(events[:len(events):len(events)], inProgress)
+	}
+	flashes := countFlashes(events)
+
+	if !a.alerted && flashes > a.flashing.flashesMax {
+		a.alerted = true
+		a.alertStart = events[0].Frame
+		alerts = append(alerts, newAlert(a.flashing.hazardType, events[0], accumulation, flashes, confirmed <= a.flashing.flashesMax))
+	} else if !a.alerted && a.flashing.pending() > 0 {
+		//The window was only found to be hazardous once it was complete
+		a.alerted = true
+		a.alertStart = a.flashing.hazardStart.Frame
+		alerts = append(alerts, newAlert(a.flashing.hazardType, a.flashing.hazardStart, accumulation, a.flashing.hazardFlashes, false))
+	}
+
+	sequence := a.extended
+	if sequence.pending() > 0 && (!a.extendedAlerted || a.extendedStart != sequence.sequenceStart.Frame) {
+		a.extendedAlerted = true
+		a.extendedStart = sequence.sequenceStart.Frame
+		alerts = append(alerts, newAlert(sequence.hazardType, sequence.sequenceStart, accumulation, 0, false))
+	}
+
+	return alerts
+}
+
+//newAlert creates an alert for flashing that started at start and was found while analyzing accumulation
+func newAlert(hazardType string, start flashEvent, accumulation BrightnessAccumulation, flashes int, provisional bool) Alert {
+	var alert Alert
+	alert.HazardType = hazardType
+	alert.StartFrame = start.Frame
+	alert.StartTime = start.Time
+	alert.Frame = accumulation.Index
+	alert.Time = accumulation.Timestamp
+	alert.Flashes = flashes
+	alert.Provisional = provisional
+	return alert
+}
+
+//newHazardAlert creates a late alert for a hazard that was finished while analyzing accumulation
+func newHazardAlert(hazard hazards.Hazard, accumulation BrightnessAccumulation, flashes int) Alert {
+	start := flashEvent{Frame: hazard.StartFrame, Time: time.Duration(hazard.StartTime * float64(time.Second))}
+	return newAlert(hazard.HazardType, start, accumulation, flashes, false)
+}
@@ -12,25 +12,35 @@ import (
 	"github.com/lycerius/epilguard/hazards"
 )
 
-//newCSVFile creates a new CSV file and returns a writeable stream
-func newCSVFile(path string) (*csv.Writer, error) {
-
+//newFile creates a file at path and returns a writer to it
+func newFile(path string) (*bufio.Writer, error) {
 	file, err := os.Create(path)
 	if err != nil {
 		return nil, err
 	}
-	writer := csv.NewWriter(file)
-	return writer, nil
+	writer := bufio.NewWriter(file)
+	return writer, err
 }
 
-//newFile creates a file at path and returns a writer to it
-func newFile(path string) (*bufio.Writer, error) {
+//writeFile creates a file at path, writes it with write and closes it,
+//returning the first error writing, flushing or closing the file
+func writeFile(path string, write func(*bufio.Writer) error) error {
 	file, err := os.Create(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
+
 	writer := bufio.NewWriter(file)
-	return writer, err
+	err = write(writer)
+
+	//A bufio.Writer keeps the first write error and returns it from Flush
+	if flushErr := writer.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 func generateCSVFileName(videoName, csvDir, datasetName string, date time.Time) string {
@@ -57,76 +67,224 @@ func generateExportItemFileName(videoName, csvDir, datasetName string, date time
 	return filepath.Join(csvDir, strconv.FormatUint(uint64(date.Unix()), 16)+"-"+normalName+"-"+datasetName)
 }
 
+//Names of the CSVs describing flashes, red transitions and patterns
+const (
+	_AccumulationDataset   = "Accumulation"
+	_FlashesDataset        = "Flashes"
+	_FrameFlashesDataset   = "FrameFlashes"
+	_RedTransitionsDataset = "RedTransitions"
+	_PatternsDataset       = "Patterns"
+)
+
 //ExportBrightnessAccumulation creates a csv using accTab at csvDir using the name of the video at path and the current time date
 func ExportBrightnessAccumulation(path string, csvDir string, accTab BrightnessAccumulationTable, date time.Time) error {
-	path = generateCSVFileName(path, csvDir, "Accumulation", date)
-	csv, err := newCSVFile(path)
-
+	stream, err := createCSVStream(path, csvDir, date, _AccumulationDataset)
 	if err != nil {
 		return err
 	}
-	defer csv.Flush()
 
-	//Write the header
-	csv.Write([]string{"Index", "Brightness", "Accumulation"})
-
-	//Write the values
 	for tableElement := accTab.Front(); tableElement != nil; tableElement = tableElement.Next() {
-		brightnessAccumulation := tableElement.Value.(BrightnessAccumulation)
-		index := strconv.FormatUint(uint64(brightnessAccumulation.Index), 10)
-		brightness := strconv.Itoa(brightnessAccumulation.Brightness)
-		accumulation := strconv.Itoa(brightnessAccumulation.Accumulation)
-		csv.Write([]string{index, brightness, accumulation})
+		err = stream.writeAccumulation(tableElement.Value.(BrightnessAccumulation))
+		if err != nil {
+			stream.close()
+			return err
+		}
 	}
-	return nil
+	return stream.close()
 }
 
 //ExportFlashTable creates a csv using flashTable at csvDir using the name of the video at path and the current time date
 func ExportFlashTable(path string, csvDir string, flashTable FlashTable, date time.Time) error {
-	path = generateCSVFileName(path, csvDir, "Flashes", date)
-	csv, err := newCSVFile(path)
+	return exportFlashTable(path, csvDir, flashTable, date, _FlashesDataset)
+}
+
+//ExportFlashTableByFrames creates a csv using flastTable at csvDir using the name of the video at path and the current time date
+func ExportFlashTableByFrames(path string, csvDir string, flashTable FlashTable, date time.Time) error {
+	return exportFlashTable(path, csvDir, flashTable, date, _FrameFlashesDataset)
+}
 
+//exportFlashTable writes every flash in flashTable to the CSV named after datasetName
+func exportFlashTable(path, csvDir string, flashTable FlashTable, date time.Time, datasetName string) error {
+	stream, err := createCSVStream(path, csvDir, date, datasetName)
 	if err != nil {
 		return err
 	}
-	defer csv.Flush()
-
-	csv.Write([]string{"Brightness", "Frames"})
 
 	for tableElement := flashTable.Front(); tableElement != nil; tableElement = tableElement.Next() {
-		flash := tableElement.Value.(Flash)
-		brightness := strconv.Itoa(flash.Brightness)
-		frames := strconv.Itoa(flash.Frames)
-		csv.Write([]string{brightness, frames})
+		err = stream.writeFlash(tableElement.Value.(Flash))
+		if err != nil {
+			stream.close()
+			return err
+		}
+	}
+	return stream.close()
+}
+
+//csvStream writes the Accumulation, Flashes, FrameFlashes, RedTransitions and Patterns CSVs a row at a time,
+//so they can be written while the video is processed instead of being held in memory
+type csvStream struct {
+	files                               []*os.File
+	accumulation, flashes, frameFlashes *csv.Writer //nil when the CSV isn't being written
+	redTransitions, patterns            *csv.Writer //nil when the CSV isn't being written
+	frameIndex                          uint64
+}
+
+//createCSVStream creates the CSVs named in datasetNames
+func createCSVStream(path, csvDir string, date time.Time, datasetNames ...string) (*csvStream, error) {
+	var stream csvStream
+	stream.frameIndex = 1
+
+	for _, datasetName := range datasetNames {
+		var writer **csv.Writer
+		var header []string
+
+		switch datasetName {
+		case _AccumulationDataset:
+			writer, header = &stream.accumulation, []string{"Index", "Brightness", "Accumulation", "Time"}
+		case _FlashesDataset:
+			writer, header = &stream.flashes, []string{"Brightness", "Frames"}
+		case _FrameFlashesDataset:
+			writer, header = &stream.frameFlashes, []string{"FrameIndex", "Brightness"}
+		case _RedTransitionsDataset:
+			writer, header = &stream.redTransitions, []string{"FrameIndex", "Direction"}
+		case _PatternsDataset:
+			writer, header = &stream.patterns, []string{"FrameIndex", "Pairs", "Area"}
+		default:
+			continue
+		}
+
+		file, err := os.Create(generateCSVFileName(path, csvDir, datasetName, date))
+		if err != nil {
+			stream.close()
+			return nil, err
+		}
+		stream.files = append(stream.files, file)
+
+		*writer = csv.NewWriter(file)
+		(*writer).Write(header)
+	}
+
+	return &stream, nil
+}
+
+//writeAccumulation adds a row to the Accumulation CSV
+func (s *csvStream) writeAccumulation(accumulation BrightnessAccumulation) error {
+	if s.accumulation == nil {
+		return nil
+	}
+
+	index := strconv.FormatUint(uint64(accumulation.Index), 10)
+	brightness := strconv.Itoa(accumulation.Brightness)
+	accumulated := strconv.Itoa(accumulation.Accumulation)
+	timestamp := strconv.FormatFloat(accumulation.Timestamp.Seconds(), 'f', 6, 64)
+	s.accumulation.Write([]string{index, brightness, accumulated, timestamp})
+	return s.accumulation.Error()
+}
+
+//writeFlash adds a row to the Flashes CSV and a row for every frame of the flash to the FrameFlashes CSV
+func (s *csvStream) writeFlash(flash Flash) error {
+	brightness := strconv.Itoa(flash.Brightness)
+
+	if s.flashes != nil {
+		s.flashes.Write([]string{brightness, strconv.Itoa(flash.Frames)})
+		if err := s.flashes.Error(); err != nil {
+			return err
+		}
+	}
+
+	if s.frameFlashes != nil {
+		for i := 0; i < flash.Frames; i++ {
+			s.frameFlashes.Write([]string{strconv.FormatUint(s.frameIndex, 10), brightness})
+			s.frameIndex++
+		}
+		return s.frameFlashes.Error()
 	}
 
 	return nil
 }
 
-//ExportFlashTableByFrames creates a csv using flastTable at csvDir using the name of the video at path and the current time date
-func ExportFlashTableByFrames(path string, csvDir string, flashTable FlashTable, date time.Time) error {
-	path = generateCSVFileName(path, csvDir, "FrameFlashes", date)
-	csv, err := newCSVFile(path)
+//writeRedTransition adds a row to the RedTransitions CSV
+func (s *csvStream) writeRedTransition(transition RedTransition) error {
+	if s.redTransitions == nil {
+		return nil
+	}
+
+	index := strconv.FormatUint(uint64(transition.Index), 10)
+	direction := strconv.Itoa(transition.Direction)
+	s.redTransitions.Write([]string{index, direction})
+	return s.redTransitions.Error()
+}
+
+//writePattern adds a row to the Patterns CSV
+func (s *csvStream) writePattern(measurement PatternMeasurement) error {
+	if s.patterns == nil {
+		return nil
+	}
+
+	index := strconv.FormatUint(uint64(measurement.Index), 10)
+	pairs := strconv.Itoa(measurement.Pairs)
+	area := strconv.FormatFloat(float64(measurement.Area), 'f', 4, 32)
+	s.patterns.Write([]string{index, pairs, area})
+	return s.patterns.Error()
+}
+
+//close flushes and closes every CSV, it is safe to close more than once
+func (s *csvStream) close() error {
+	var err error
+	for _, writer := range []*csv.Writer{s.accumulation, s.flashes, s.frameFlashes, s.redTransitions, s.patterns} {
+		if writer == nil {
+			continue
+		}
+		writer.Flush()
+		if flushErr := writer.Error(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}
+
+	for _, file := range s.files {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	s.accumulation, s.flashes, s.frameFlashes = nil, nil, nil
+	s.redTransitions, s.patterns = nil, nil
+	s.files = nil
+	return err
+}
 
+//ExportRedTransitionTable creates a csv using transitions at csvDir using the name of the video at path and the current time date
+func ExportRedTransitionTable(path string, csvDir string, transitions RedTransitionTable, date time.Time) error {
+	stream, err := createCSVStream(path, csvDir, date, _RedTransitionsDataset)
 	if err != nil {
 		return err
 	}
-	defer csv.Flush()
 
-	csv.Write([]string{"FrameIndex", "Brightness"})
+	for tableElement := transitions.Front(); tableElement != nil; tableElement = tableElement.Next() {
+		err = stream.writeRedTransition(tableElement.Value.(RedTransition))
+		if err != nil {
+			stream.close()
+			return err
+		}
+	}
+	return stream.close()
+}
 
-	var frameIndex uint64 = 1
-	for tableElement := flashTable.Front(); tableElement != nil; tableElement = tableElement.Next() {
-		flash := tableElement.Value.(Flash)
-		brightness := strconv.Itoa(flash.Brightness)
-		frameCount := flash.Frames
-		for i := 0; i < frameCount; i++ {
-			frameIndexS := strconv.FormatUint(frameIndex, 10)
-			csv.Write([]string{frameIndexS, brightness})
-			frameIndex++
+//ExportPatternTable creates a csv using patterns at csvDir using the name of the video at path and the current time date
+func ExportPatternTable(path string, csvDir string, patterns PatternTable, date time.Time) error {
+	stream, err := createCSVStream(path, csvDir, date, _PatternsDataset)
+	if err != nil {
+		return err
+	}
+
+	for tableElement := patterns.Front(); tableElement != nil; tableElement = tableElement.Next() {
+		err = stream.writePattern(tableElement.Value.(PatternMeasurement))
+		if err != nil {
+			stream.close()
+			return err
 		}
 	}
-	return nil
+	return stream.close()
 }
 
 //ExportHazardReport creates a json file using report  at csvDir using the name of the video at path and the current time date
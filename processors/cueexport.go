@@ -0,0 +1,87 @@
+package processors
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lycerius/epilguard/hazards"
+)
+
+//What cues call every type of hazard
+var cueHazardNames = map[string]string{
+	hazards.FlashHazard:         "Flashing",
+	hazards.ExtendedFlashHazard: "Extended flashing",
+	hazards.RedFlashHazard:      "Red flashing",
+	hazards.PatternHazard:       "Regular pattern",
+}
+
+//hazardCue is when a cue is shown and what it says
+type hazardCue struct {
+	Start, End time.Duration
+	Text       string
+}
+
+//ExportWebVTTCues creates a WebVTT file with a cue for every hazard of report at csvDir using the name of the video at path and the current time date
+func ExportWebVTTCues(path, csvDir string, report hazards.HazardReport, date time.Time) error {
+	return writeFile(generateExportItemFileName(path, csvDir, "Hazards", date)+".vtt", func(file *bufio.Writer) error {
+		fmt.Fprint(file, "WEBVTT\n\n")
+		for i, cue := range createHazardCues(report) {
+			fmt.Fprintf(file, "hazard-%d\n%s --> %s\n%s\n\n", i+1, formatCueTime(cue.Start, '.'), formatCueTime(cue.End, '.'), cue.Text)
+		}
+		return nil
+	})
+}
+
+//ExportSRTCues creates a SubRip file with a cue for every hazard of report at csvDir using the name of the video at path and the current time date
+func ExportSRTCues(path, csvDir string, report hazards.HazardReport, date time.Time) error {
+	return writeFile(generateExportItemFileName(path, csvDir, "Hazards", date)+".srt", func(file *bufio.Writer) error {
+		for i, cue := range createHazardCues(report) {
+			fmt.Fprintf(file, "%d\n%s --> %s\n%s\n\n", i+1, formatCueTime(cue.Start, ','), formatCueTime(cue.End, ','), cue.Text)
+		}
+		return nil
+	})
+}
+
+//createHazardCues creates a cue for every hazard in report in the order they start, as cue files require.
+//A cue starts on the hazard's first frame and ends as its last frame stops being shown
+func createHazardCues(report hazards.HazardReport) []hazardCue {
+	var cues []hazardCue
+
+	for hazardElement := report.Hazards.Front(); hazardElement != nil; hazardElement = hazardElement.Next() {
+		hazard := hazardElement.Value.(hazards.Hazard)
+
+		name, ok := cueHazardNames[hazard.HazardType]
+		if !ok {
+			name = hazard.HazardType
+		}
+
+		var cue hazardCue
+		cue.Start = truncateCueTime(hazard.StartTime)
+		cue.End = truncateCueTime(hazard.EndTime)
+		cue.Text = fmt.Sprintf("Photosensitivity warning: %s, %s severity", name, hazard.Severity())
+		cues = append(cues, cue)
+	}
+
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Start < cues[j].Start
+	})
+	return cues
+}
+
+//truncateCueTime converts seconds to the whole millisecond cue files count in, rounding down.
+//Players show the frame at a time until the next frame's time, so as long as frames are more than a millisecond apart
+//a cue rounded down still starts on its first frame and stops before the frame after its last one
+func truncateCueTime(seconds float64) time.Duration {
+	//Seconds are rounded to the nanosecond first, so 0.1 isn't taken for 0.0999...
+	nanoseconds := math.Round(seconds * float64(time.Second))
+	return time.Duration(nanoseconds).Truncate(time.Millisecond)
+}
+
+//formatCueTime formats t as HH:MM:SS followed by the milliseconds after separator, '.' for WebVTT and ',' for SubRip
+func formatCueTime(t time.Duration, separator byte) string {
+	milliseconds := int64(t / time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, separator, milliseconds%1000)
+}
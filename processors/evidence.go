@@ -0,0 +1,263 @@
+package processors
+
+import (
+	"container/list"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/lycerius/epilguard/decoder"
+	"github.com/lycerius/epilguard/hazards"
+)
+
+//How many frames across and down a contact sheet shows
+const (
+	_ContactSheetColumns = 4
+	_ContactSheetRows    = 3
+)
+
+//How many frames of a contact sheet come before the frame a hazard's biggest change ends on,
+//so the change sits in the middle of the sheet
+const _ContactSheetLead = 6
+
+//How wide every frame of a contact sheet is drawn and how much space is left around it
+const (
+	_ContactSheetFrameWidth = 240
+	_ContactSheetGap        = 4
+)
+
+//How many pixels wide and tall every dot of a label glyph is drawn
+const _LabelScale = 2
+
+//labelGlyphs are 3x5 dot glyphs for the characters of frame labels, every row is 3 bits read from the left
+var labelGlyphs = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7},
+	'1': {2, 6, 2, 2, 7},
+	'2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 3, 1, 7},
+	'4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7},
+	'7': {7, 1, 1, 1, 1},
+	'8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	':': {0, 2, 0, 2, 0},
+	'#': {5, 7, 5, 7, 5},
+	' ': {0, 0, 0, 0, 0},
+}
+
+//The color the biggest change of a hazard is outlined with on its contact sheet
+var evidenceOutline = color.RGBA{255, 0, 0, 255}
+
+//SourceOpener opens another reading of the video being analyzed
+type SourceOpener func(ctx context.Context) (decoder.FrameSource, error)
+
+//hazardEvidence collects the frames that show what a hazard looks like: the frames either side of the
+//biggest change in the picture during the hazard, and the frames around them for a contact sheet
+type hazardEvidence struct {
+	hazard        *list.Element
+	start, end    uint
+	change        int           //Biggest change found so far, the sum of how much every pixel changed in brightness
+	before, after decoder.Frame //Frames the biggest change goes from and to
+	sheet         []decoder.Frame
+}
+
+//exportEvidence reads the video opened by open again and writes PNGs of the frames either side of the biggest change
+//in the picture during every hazard in report, and a contact sheet of the frames around them, recording their paths in the hazards
+func exportEvidence(ctx context.Context, open SourceOpener, path, csvDir string, report hazards.HazardReport, date time.Time) error {
+	if report.Hazards.Len() == 0 {
+		return nil
+	}
+
+	source, err := open(ctx)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	evidence, err := collectEvidence(ctx, source, report)
+	if err != nil {
+		return err
+	}
+
+	fps := source.FrameRate()
+
+	for i, found := range evidence {
+		if len(found.sheet) == 0 {
+			continue
+		}
+
+		hazard := found.hazard.Value.(hazards.Hazard)
+		datasetName := hazard.HazardType + "-" + strconv.Itoa(i+1)
+
+		before, err := exportPNG(path, csvDir, "Evidence-"+datasetName+"-Frame-"+strconv.Itoa(int(found.before.Index)), found.before, date)
+		if err != nil {
+			return err
+		}
+
+		after, err := exportPNG(path, csvDir, "Evidence-"+datasetName+"-Frame-"+strconv.Itoa(int(found.after.Index)), found.after, date)
+		if err != nil {
+			return err
+		}
+
+		contactSheet, err := exportPNG(path, csvDir, "ContactSheet-"+datasetName, found.contactSheet(fps), date)
+		if err != nil {
+			return err
+		}
+
+		hazard.Evidence = []string{before, after}
+		hazard.ContactSheet = contactSheet
+		found.hazard.Value = hazard
+	}
+
+	return nil
+}
+
+//collectEvidence reads source up to the last frame a contact sheet needs and finds the evidence of every hazard in report,
+//only the frames the evidence is made of are kept
+func collectEvidence(ctx context.Context, source decoder.FrameSource, report hazards.HazardReport) ([]hazardEvidence, error) {
+	var evidence []hazardEvidence
+	var last uint
+
+	for element := report.Hazards.Front(); element != nil; element = element.Next() {
+		hazard := element.Value.(hazards.Hazard)
+		evidence = append(evidence, hazardEvidence{hazard: element, start: hazard.StartFrame, end: hazard.EndFrame, change: -1})
+		if hazard.EndFrame > last {
+			last = hazard.EndFrame
+		}
+	}
+
+	sheetSize := _ContactSheetColumns * _ContactSheetRows
+	last += uint(sheetSize - _ContactSheetLead - 1)
+
+	var recent []decoder.Frame //Frames before the current one that a contact sheet can start with
+	var previous brightnessFrame
+
+	for {
+		frame, err := decoder.NextFrameContext(ctx, source)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		brightness := rGBFrameToBrightness(frame)
+
+		for i := range evidence {
+			found := &evidence[i]
+
+			//Frames after the biggest change so far finish its contact sheet
+			if len(found.sheet) > 0 && len(found.sheet) < sheetSize {
+				found.sheet = append(found.sheet, frame)
+			}
+
+			if len(recent) == 0 || frame.Index < found.start || frame.Index > found.end {
+				continue
+			}
+
+			if change := brightnessChange(previous, brightness); change > found.change {
+				found.change = change
+				found.before = recent[len(recent)-1]
+				found.after = frame
+				found.sheet = append(append([]decoder.Frame(nil), recent...), frame)
+			}
+		}
+
+		recent = append(recent, frame)
+		if len(recent) > _ContactSheetLead {
+			recent = recent[1:]
+		}
+		previous = brightness
+
+		if frame.Index >= last {
+			break
+		}
+	}
+
+	return evidence, nil
+}
+
+//brightnessChange sums how much every pixel changed in brightness between two frames
+func brightnessChange(f1, f2 brightnessFrame) int {
+	var change int
+	for i, brightness := range f2.Pixels {
+		difference := brightness - f1.Pixels[i]
+		if difference < 0 {
+			difference = -difference
+		}
+		change += difference
+	}
+	return change
+}
+
+//contactSheet draws the frames around the biggest change in a grid, labelled with their index and timecode at fps.
+//The frames of the change are outlined
+func (e hazardEvidence) contactSheet(fps int) *image.RGBA {
+	cellWidth := _ContactSheetFrameWidth
+	cellHeight := e.after.Height * cellWidth / e.after.Width
+	if cellHeight < 1 {
+		cellHeight = 1
+	}
+
+	bounds := image.Rect(0, 0, _ContactSheetColumns*(cellWidth+_ContactSheetGap)+_ContactSheetGap, _ContactSheetRows*(cellHeight+_ContactSheetGap)+_ContactSheetGap)
+	sheet := image.NewRGBA(bounds)
+	draw.Draw(sheet, bounds, image.Black, image.Point{}, draw.Src)
+
+	for i, frame := range e.sheet {
+		x := _ContactSheetGap + i%_ContactSheetColumns*(cellWidth+_ContactSheetGap)
+		y := _ContactSheetGap + i/_ContactSheetColumns*(cellHeight+_ContactSheetGap)
+		cell := image.Rect(x, y, x+cellWidth, y+cellHeight)
+
+		if frame.Index == e.before.Index || frame.Index == e.after.Index {
+			draw.Draw(sheet, cell.Inset(-_ContactSheetGap/2), image.NewUniform(evidenceOutline), image.Point{}, draw.Src)
+		}
+
+		drawScaledFrame(sheet, cell, frame)
+		drawLabel(sheet, x+_LabelScale, y+_LabelScale, "#"+strconv.Itoa(int(frame.Index))+" "+hazards.Timecode(frame.Timestamp, fps))
+	}
+
+	return sheet
+}
+
+//drawScaledFrame draws frame stretched over cell of dst, picking the nearest pixel
+func drawScaledFrame(dst *image.RGBA, cell image.Rectangle, frame decoder.Frame) {
+	pixels := frame.Pixels()
+	width, height := cell.Dx(), cell.Dy()
+
+	for y := 0; y < height; y++ {
+		row := y * frame.Height / height * frame.Width
+		for x := 0; x < width; x++ {
+			source := (row + x*frame.Width/width) * 3
+			offset := dst.PixOffset(cell.Min.X+x, cell.Min.Y+y)
+			copy(dst.Pix[offset:offset+3], pixels[source:source+3])
+			dst.Pix[offset+3] = 255
+		}
+	}
+}
+
+//drawLabel writes text in white on a black box with its top left corner at x, y, characters without a glyph are left blank
+func drawLabel(dst *image.RGBA, x, y int, text string) {
+	advance := 4 * _LabelScale
+	box := image.Rect(x, y, x+len(text)*advance+_LabelScale, y+7*_LabelScale).Intersect(dst.Bounds())
+	draw.Draw(dst, box, image.Black, image.Point{}, draw.Src)
+
+	for i, character := range text {
+		glyph := labelGlyphs[character]
+		for row, bits := range glyph {
+			for column := 0; column < 3; column++ {
+				if bits&(4>>uint(column)) == 0 {
+					continue
+				}
+
+				left := x + _LabelScale + i*advance + column*_LabelScale
+				top := y + _LabelScale + row*_LabelScale
+				dot := image.Rect(left, top, left+_LabelScale, top+_LabelScale).Intersect(box)
+				draw.Draw(dst, dot, image.White, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
@@ -2,20 +2,37 @@ package processors
 
 import (
 	"container/list"
+	"context"
 	"math"
 	"time"
 
 	"github.com/lycerius/epilguard/decoder"
 	"github.com/lycerius/epilguard/equations"
 	"github.com/lycerius/epilguard/hazards"
+	"github.com/lycerius/epilguard/profiles"
 )
 
 //FlashingProcessor Processes a video stream and detects flashing photosensitive content
 type FlashingProcessor struct {
-	decoder         *decoder.Decoder     //Decoder to fetch frames from
+	source          decoder.FrameSource  //Source to fetch frames from
 	ReportDirectory string               //The job assosiated with this request
 	HazardReport    hazards.HazardReport //Generated hazard report
 	AreaThreshold   float32
+	Profile         profiles.Profile      //Rules that decide what is hazardous
+	Heatmaps        bool                  //Whether to export a heatmap PNG for every hazard
+	Evidence        SourceOpener          //Opens the video again to export the frames of every hazard's biggest change and a contact sheet, if set
+	RecordTimeline  bool                  //Whether to record Timeline for ExportHTMLReport
+	Timeline        Timeline              //What the brightness did over time, only recorded with RecordTimeline or HTMLReport
+	HTMLReport      bool                  //Whether to also export the report as a self contained HTML page charting Timeline
+	Cues            bool                  //Whether to also export the hazards as WebVTT and SRT cue tracks
+	RedFlashes      bool                  //Whether to also look for saturated red flashes, their hazards are part of HazardReport
+	Patterns        bool                  //Whether to also look for regular patterns, their hazards are part of HazardReport
+	Workers         int                   //How many frames are converted to brightness at once
+	Progress        ProgressFunc          //Called with the progress of the analysis, if set
+	Hazards         chan<- hazards.Hazard //Every hazard is sent here as soon as it is finished, if set. It is not closed
+	Alerts          chan<- Alert          //An alert is sent here as soon as flashing becomes hazardous, if set. It is not closed
+	EarlyAlerts     bool                  //Whether alerts count the transition in progress, raising them sooner but sometimes wrongly
+	SkipExport      bool                  //Whether to skip writing the report files, such as when monitoring a live stream
 }
 
 //brightnessFrame Describes the pixel brightness transition between two frames
@@ -31,6 +48,9 @@ type frameBrightnessDelta struct {
 	Height, Width                  int
 	NegativePixels, PositivePixels map[int]int
 	MaxPos, MaxNeg                 int
+	PositiveDarker, NegativeDarker int   //Average brightness of the darker image for the pixels that got brighter or darker
+	Deltas                         []int //Change in brightness of every pixel
+	Darker                         []int //Brightness of the darker image at every pixel
 }
 
 //BrightnessAccumulationTable a list of Brightness Accumulations
@@ -39,7 +59,10 @@ type BrightnessAccumulationTable = *list.List
 //BrightnessAccumulation holds the average brightness and the total accumulation over time
 type BrightnessAccumulation struct {
 	Index                    uint
+	Timestamp                time.Duration //Presentation time of the frame
 	Brightness, Accumulation int
+	Darker                   int        //Average brightness of the darker image among the changing pixels
+	mask                     regionMask //Areas of the screen that changed
 }
 
 //FlashTable is a list of flashes
@@ -48,118 +71,243 @@ type FlashTable = *list.List
 //Flash describes the maximum brightness achieved over a set of frames before an inversion
 type Flash struct {
 	Brightness, Frames int
+	Start, End         uint          //First and last frame index of the trend
+	StartTime, EndTime time.Duration //When the first frame is shown and when the last frame stops being shown
+	Peak               uint          //Frame index where the trend reaches Brightness
+	PeakTime           time.Duration //When the Peak frame is shown
+	Darker             int           //Brightness of the darker image over the trend
 }
 
 //NewFlashingProcessor creates a flashing processor
-func NewFlashingProcessor(f *decoder.Decoder, reportDir string) FlashingProcessor {
+func NewFlashingProcessor(f decoder.FrameSource, reportDir string) FlashingProcessor {
 	var processor FlashingProcessor
 
-	processor.decoder = f
+	processor.source = f
 	processor.ReportDirectory = reportDir
+	processor.Profile = profiles.Default()
+	processor.Workers = defaultWorkers()
 
 	return processor
 }
 
 //Process scans a video for photosensitive content and exports it to reportDir
 func (proc *FlashingProcessor) Process() error {
+	return proc.ProcessContext(context.Background())
+}
+
+//ProcessContext is Process, but stops decoding and returns ctx.Err() once ctx is done.
+//Flashes and hazards are found as frames arrive, so only the frames hazards in progress could span are kept in memory
+func (proc *FlashingProcessor) ProcessContext(ctx context.Context) error {
+	progress := newProgressTracker(proc.source, proc.Progress)
+	now := time.Now()
 
-	brightnessAcc, err := createBrightnessAccumulationTable(proc.decoder)
+	datasets := []string{_AccumulationDataset, _FlashesDataset, _FrameFlashesDataset}
+	if proc.RedFlashes {
+		datasets = append(datasets, _RedTransitionsDataset)
+	}
+	if proc.Patterns {
+		datasets = append(datasets, _PatternsDataset)
+	}
+	if proc.SkipExport {
+		datasets = nil
+	}
+
+	csvs, err := createCSVStream(proc.source.Name(), proc.ReportDirectory, now, datasets...)
 	if err != nil {
 		return err
 	}
+	defer csvs.close()
 
-	flashes := createFlashTable(brightnessAcc)
+	width, height := proc.source.Dimensions()
+	stream := newFlashStream(proc.Profile, proc.source.FrameRate(), width, height, proc.EarlyAlerts)
 
-	report := createHazardReport(flashes, proc.decoder.FramesPerSecond)
-	report.CreatedOn = time.Now()
+	//Red flashes and patterns are found in the same frames, with their own detectors
+	var red *redFlashStream
+	if proc.RedFlashes {
+		red = newRedFlashStream(proc.Profile, proc.source.FrameRate(), width, height)
+	}
+	var pattern *patternStream
+	if proc.Patterns {
+		pattern = newPatternStream(proc.Profile, proc.source.FrameRate(), width, height)
+	}
 
-	proc.exportReport(brightnessAcc, flashes, report)
-	proc.HazardReport = report
+	progress.countHazards(func() int {
+		count := stream.hazardCount()
+		if red != nil {
+			count += red.hazardCount()
+		}
+		if pattern != nil {
+			count += pattern.hazardCount()
+		}
+		return count
+	})
 
-	return nil
-}
+	var timeline *timelineRecorder
+	if proc.RecordTimeline || proc.HTMLReport {
+		timeline = newTimelineRecorder(proc.Profile)
+	}
 
-//exportReport exports the report to ReportDirectory
-func (proc *FlashingProcessor) exportReport(brightnessAcc BrightnessAccumulationTable, flashes FlashTable, report hazards.HazardReport) error {
-	now := time.Now()
+	analyses := frameAnalyses{Brightness: true, Red: proc.RedFlashes, Pattern: proc.Patterns}
+	err = analyzeFrames(ctx, proc.source, proc.Profile, analyses, proc.Workers, progress, func(analysis frameAnalysis) error {
+		var otherHazards []hazards.Hazard
+		if red != nil {
+			transition, finished := red.add(analysis.Red)
+			if transition {
+				err := csvs.writeRedTransition(analysis.Red)
+				if err != nil {
+					return err
+				}
+			}
+			otherHazards = append(otherHazards, finished...)
+		}
+
+		if pattern != nil {
+			otherHazards = append(otherHazards, pattern.add(analysis.Pattern)...)
+			err := csvs.writePattern(analysis.Pattern)
+			if err != nil {
+				return err
+			}
+		}
 
-	err := ExportBrightnessAccumulation(proc.decoder.FileName, proc.ReportDirectory, brightnessAcc, now)
+		//The first frame is only a baseline for brightness
+		if analysis.Baseline {
+			return proc.handleFlashes(ctx, csvs, flashUpdate{Hazards: otherHazards})
+		}
+
+		accumulation := analysis.Accumulation
+		err := csvs.writeAccumulation(accumulation)
+		if err != nil {
+			return err
+		}
+
+		update := stream.add(accumulation)
+		update.Hazards = append(update.Hazards, otherHazards...)
+		if timeline != nil {
+			timeline.add(accumulation, update.Flashes)
+		}
+		return proc.handleFlashes(ctx, csvs, update)
+	})
 	if err != nil {
 		return err
 	}
 
-	err = ExportFlashTable(proc.decoder.FileName, proc.ReportDirectory, flashes, now)
-	if err != nil {
-		return err
+	update := stream.finish()
+	if red != nil {
+		update.Hazards = append(update.Hazards, red.finish()...)
+	}
+	if pattern != nil {
+		update.Hazards = append(update.Hazards, pattern.finish()...)
+	}
+	if timeline != nil {
+		timeline.addFlashes(update.Flashes)
 	}
 
-	err = ExportFlashTableByFrames(proc.decoder.FileName, proc.ReportDirectory, flashes, now)
+	err = proc.handleFlashes(ctx, csvs, update)
 	if err != nil {
 		return err
 	}
 
-	err = ExportHazardReport(proc.decoder.FileName, proc.ReportDirectory, report, now)
+	err = csvs.close()
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-//createBrightnessAccumulationTable decodes all frames and creates a brightness accumulation table
-func createBrightnessAccumulationTable(decoder *decoder.Decoder) (BrightnessAccumulationTable, error) {
-	brightnessAcc := list.New()
+	var report hazards.HazardReport
+	heatmaps := stream.addTo(&report)
+	if red != nil {
+		heatmaps = append(heatmaps, red.addTo(&report)...)
+	}
+	if pattern != nil {
+		heatmaps = append(heatmaps, pattern.addTo(&report)...)
+	}
+	report.CreatedOn = time.Now()
+	report.Profile = proc.Profile.Name
+	progress.finish(report.Hazards.Len())
 
-	//First frame for baseline brightness
-	frame, err := decoder.NextFrame()
+	proc.HazardReport = report
+	if timeline != nil {
+		proc.Timeline = timeline.finish()
+	}
 
-	if err != nil {
-		return nil, err
+	if proc.SkipExport {
+		return nil
 	}
+	return proc.exportReport(ctx, report, heatmaps, now)
+}
 
-	var accBrightness int
-	firstFrame := rGBFrameToBrightness(frame)
-	lastFrame := &firstFrame
+//handleFlashes writes the flashes to their CSVs and sends the hazards to Hazards and the alerts to Alerts
+func (proc *FlashingProcessor) handleFlashes(ctx context.Context, csvs *csvStream, update flashUpdate) error {
+	for _, flash := range update.Flashes {
+		err := csvs.writeFlash(flash)
+		if err != nil {
+			return err
+		}
+	}
 
-	for {
-		frame, err := decoder.NextFrame()
+	//An alert comes before the hazard it belongs to
+	if proc.Alerts != nil {
+		for _, alert := range update.Alerts {
+			select {
+			case proc.Alerts <- alert:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
 
-		if err != nil {
-			//This is an OK error, just EOF
-			if err.Error() == "EOF" {
-				break
-			} else {
-				return nil, err
+	if proc.Hazards != nil {
+		for _, hazard := range update.Hazards {
+			select {
+			case proc.Hazards <- hazard:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
+	}
+	return nil
+}
 
-		//Calculations
-		brightnessFrame := rGBFrameToBrightness(frame)
-		difference := calculateFrameDifference(*lastFrame, brightnessFrame)
-		averageBrightness := findAverageBrightness(difference)
+//exportReport exports the report, heatmaps, evidence, cues and HTML page to ReportDirectory, the CSVs are written while processing.
+//The HTML page embeds the evidence, so it is exported last
+func (proc *FlashingProcessor) exportReport(ctx context.Context, report hazards.HazardReport, heatmaps []regionHeatmap, now time.Time) error {
+	if proc.Heatmaps {
+		err := exportHeatmaps(proc.source.Name(), proc.ReportDirectory, report, heatmaps, now)
+		if err != nil {
+			return err
+		}
+	}
 
-		//If signs are equal, or no change, accumulate
-		if (accBrightness < 0) == (averageBrightness < 0) || averageBrightness == 0 {
-			accBrightness += averageBrightness
-		} else {
-			//If signs are different, then an inversion occured. Start new accumulation
-			accBrightness = averageBrightness
+	if proc.Evidence != nil {
+		err := exportEvidence(ctx, proc.Evidence, proc.source.Name(), proc.ReportDirectory, report, now)
+		if err != nil {
+			return err
 		}
+	}
+
+	err := ExportHazardReport(proc.source.Name(), proc.ReportDirectory, report, now)
+	if err != nil {
+		return err
+	}
 
-		lastFrame = &brightnessFrame
+	if proc.Cues {
+		err = ExportWebVTTCues(proc.source.Name(), proc.ReportDirectory, report, now)
+		if err != nil {
+			return err
+		}
 
-		//Create new entry
-		var accumulation BrightnessAccumulation
-		accumulation.Index = frame.Index
-		accumulation.Accumulation = accBrightness
-		accumulation.Brightness = averageBrightness
-		brightnessAcc.PushBack(accumulation)
+		err = ExportSRTCues(proc.source.Name(), proc.ReportDirectory, report, now)
+		if err != nil {
+			return err
+		}
 	}
 
-	return brightnessAcc, nil
+	if proc.HTMLReport {
+		return ExportHTMLReport(proc.source.Name(), proc.ReportDirectory, report, proc.Timeline, now)
+	}
+	return nil
 }
 
-//rGBFrameToBrightness converts an RGB frame to brightness
+//rGBFrameToBrightness converts an RGB or YUV frame to brightness
 func rGBFrameToBrightness(frame decoder.Frame) brightnessFrame {
 
 	var lframe brightnessFrame
@@ -169,11 +317,15 @@ func rGBFrameToBrightness(frame decoder.Frame) brightnessFrame {
 
 	pixelBuffer := make([]int, size, size)
 
-	for y := 0; y < frame.Height; y++ {
-		for x := 0; x < frame.Width; x++ {
-			pixel := frame.GetRGB(x, y)
-			brightness := equations.RGBtoBrightness(pixel.Red, pixel.Green, pixel.Blue)
-			pixelBuffer[y*frame.Width+x] = brightness
+	//Frames from YUV sources already have luma, no need to go through RGB
+	if luma := frame.Luma(); luma != nil {
+		for i := 0; i < size; i++ {
+			pixelBuffer[i] = equations.LumaToBrightness(int(luma[i]))
+		}
+	} else {
+		pixels := frame.Pixels()
+		for i := 0; i < size; i++ {
+			pixelBuffer[i] = equations.RGBtoBrightness(int(pixels[i*3]), int(pixels[i*3+1]), int(pixels[i*3+2]))
 		}
 	}
 
@@ -186,24 +338,43 @@ func rGBFrameToBrightness(frame decoder.Frame) brightnessFrame {
 func calculateFrameDifference(f1, f2 brightnessFrame) frameBrightnessDelta {
 	var frameDifference frameBrightnessDelta
 	var maxpos, maxneg int
+	var positiveCount, negativeCount, positiveDarkerSum, negativeDarkerSum int
 	positives := make(map[int]int)
 	negatives := make(map[int]int)
+	deltas := make([]int, f1.Height*f1.Width)
+	darker := make([]int, f1.Height*f1.Width)
 
 	for i := 0; i < f1.Height*f1.Width; i++ {
 		difference := f2.Pixels[i] - f1.Pixels[i]
+		deltas[i] = difference
+		darker[i] = f1.Pixels[i]
+		if difference < 0 {
+			darker[i] = f2.Pixels[i]
+		}
+
 		if difference > 0 {
 			positives[difference]++
+			positiveCount++
+			positiveDarkerSum += f1.Pixels[i]
 			if difference > maxpos {
 				maxpos = difference
 			}
 		} else if difference < 0 {
 			difference = -difference
 			negatives[difference]++
+			negativeCount++
+			negativeDarkerSum += f2.Pixels[i]
 			if difference > maxneg {
 				maxneg = difference
 			}
 		}
 	}
+	if positiveCount > 0 {
+		frameDifference.PositiveDarker = positiveDarkerSum / positiveCount
+	}
+	if negativeCount > 0 {
+		frameDifference.NegativeDarker = negativeDarkerSum / negativeCount
+	}
 	frameDifference.Height = f1.Height
 	frameDifference.Width = f1.Width
 	frameDifference.PositivePixels = positives
@@ -211,20 +382,96 @@ func calculateFrameDifference(f1, f2 brightnessFrame) frameBrightnessDelta {
 	frameDifference.Index = f2.Index
 	frameDifference.MaxNeg = maxneg
 	frameDifference.MaxPos = maxpos
+	frameDifference.Deltas = deltas
+	frameDifference.Darker = darker
 	return frameDifference
 }
 
+//createFlashMask marks the areas of the screen where pixels changed by at least flashDelta, getting darker or brighter
+func createFlashMask(fd frameBrightnessDelta, darker bool, flashDelta float32) regionMask {
+	return createRegionMask(fd.Width, fd.Height, func(position int) bool {
+		delta := fd.Deltas[position]
+		if darker {
+			delta = -delta
+		}
+		return float32(delta) >= flashDelta
+	})
+}
+
 //findAverageBrightness takes the calculated brightness differences and chooses the positive or negative bin
-//depending on which bin has the largest magnitude
-func findAverageBrightness(fd frameBrightnessDelta) int {
-	elementsRequired := int(float32(fd.Height*fd.Width) * equations.PercentageFlashArea)
+//depending on which bin has the largest magnitude, it also returns the brightness of the darker image for that bin
+func findAverageBrightness(fd frameBrightnessDelta, area profiles.AreaRules) (int, int) {
+	window := newViewport(area, fd.Width, fd.Height)
+	if !window.coversFrame(fd.Width, fd.Height) {
+		return findWindowAverageBrightness(fd, window, area.Fraction)
+	}
+
+	elementsRequired := int(float32(fd.Height*fd.Width) * area.Fraction)
 	positive := calculateAverageBrightness(fd.PositivePixels, elementsRequired, fd.MaxPos)
 	negative := calculateAverageBrightness(fd.NegativePixels, elementsRequired, fd.MaxNeg)
 
 	if positive >= negative {
-		return positive
+		return positive, fd.PositiveDarker
 	}
-	return -negative
+	return -negative, fd.NegativeDarker
+}
+
+//findWindowAverageBrightness is findAverageBrightness for a viewport smaller than the frame.
+//The window with the most positive change and the window with the most negative change are measured,
+//so a small flashing region counts even when the rest of the frame is still
+func findWindowAverageBrightness(fd frameBrightnessDelta, window viewport, areaFraction float32) (int, int) {
+	size := fd.Height * fd.Width
+	positiveChange := make([]int, size)
+	negativeChange := make([]int, size)
+
+	for i, delta := range fd.Deltas {
+		if delta > 0 {
+			positiveChange[i] = delta
+		} else {
+			negativeChange[i] = -delta
+		}
+	}
+
+	elementsRequired := int(float32(window.Width*window.Height) * areaFraction)
+	positive, positiveDarker := measureBusiestWindow(fd, positiveChange, window, elementsRequired)
+	negative, negativeDarker := measureBusiestWindow(fd, negativeChange, window, elementsRequired)
+
+	if positive >= negative {
+		return positive, positiveDarker
+	}
+	return -negative, negativeDarker
+}
+
+//measureBusiestWindow finds the window with the most change and calculates its average brightness
+//and the average brightness of its darker image
+func measureBusiestWindow(fd frameBrightnessDelta, change []int, window viewport, elementsRequired int) (int, int) {
+	ii := newIntegralImage(change, fd.Width, fd.Height)
+	left, top, _ := findBusiestWindow(ii, window, fd.Width, fd.Height)
+
+	histogram := make(map[int]int)
+	var maxBrightness, changedCount, darkerSum int
+	for y := top; y < top+window.Height; y++ {
+		for x := left; x < left+window.Width; x++ {
+			position := y*fd.Width + x
+			brightness := change[position]
+			if brightness == 0 {
+				continue
+			}
+
+			histogram[brightness]++
+			changedCount++
+			darkerSum += fd.Darker[position]
+			if brightness > maxBrightness {
+				maxBrightness = brightness
+			}
+		}
+	}
+
+	if changedCount == 0 || elementsRequired == 0 {
+		return 0, 0
+	}
+
+	return calculateAverageBrightness(histogram, elementsRequired, maxBrightness), darkerSum / changedCount
 }
 
 /*
@@ -259,148 +506,105 @@ func calculateAverageBrightness(histogram map[int]int, pixelsRequired, maxBright
 	return averageDifference
 }
 
-//createFlashTable takes brightness accumulations and compresses it to just inversions
-//and how many frames the brightness trend lasted before the inversion
-func createFlashTable(brightnessAcc BrightnessAccumulationTable) FlashTable {
-	flashTable := list.New()
-
-	localMaxima := (brightnessAcc.Front().Value.(BrightnessAccumulation)).Accumulation
-	var amountOfFrames int
-
-	brightnessElement := brightnessAcc.Front()
-
-	for {
-		//No more elements, push the last entry
-		if brightnessElement == nil {
-			var extreme Flash
-			extreme.Frames = amountOfFrames
-			extreme.Brightness = localMaxima
-			flashTable.PushBack(extreme)
-			break
-		}
-
-		accumulation := brightnessElement.Value.(BrightnessAccumulation)
-		brightness := accumulation.Accumulation
+//flashTracker compresses brightness accumulations to just inversions one accumulation at a time,
+//recording how many frames the brightness trend lasted before the inversion
+type flashTracker struct {
+	started       bool
+	firstIndex    uint
+	trend         Flash //Trend in progress, its EndTime isn't known until it ends
+	lastTimestamp time.Duration
+	lastDuration  time.Duration //How long the frame before the last one was shown
+}
 
-		//Signs are equal, trend continues
-		if (brightness < 0) == (localMaxima < 0) {
-			amountOfFrames++
-			if math.Abs(float64(localMaxima)) < math.Abs(float64(brightness)) {
-				localMaxima = brightness
-			}
-		} else {
-			//Inversion occured
-			var extreme Flash
-			extreme.Frames = amountOfFrames
-			extreme.Brightness = localMaxima
-			flashTable.PushBack(extreme)
-			amountOfFrames = 1
-			localMaxima = brightness
+//add takes the next accumulation, returning the flash it ended if the brightness trend inverted
+func (t *flashTracker) add(accumulation BrightnessAccumulation) (Flash, bool) {
+	brightness := accumulation.Accumulation
+
+	if !t.started {
+		t.started = true
+		t.firstIndex = accumulation.Index
+		t.trend = newTrend(accumulation)
+		t.trend.Frames = 0
+		t.trend.Darker = math.MaxInt32
+		if accumulation.Brightness != 0 {
+			t.trend.Darker = accumulation.Darker
 		}
-
-		brightnessElement = brightnessElement.Next()
+	} else if accumulation.Index != t.firstIndex {
+		t.lastDuration = accumulation.Timestamp - t.lastTimestamp
 	}
-
-	return flashTable
-}
-
-func createHazardReport(brightnessExtTab FlashTable, fps int) hazards.HazardReport {
-	var hazardReport hazards.HazardReport
-
-	flashesPerSecondThreshold := equations.FlashFrequencyMax
-	frameCounter := 0
-	countedFlashes := 0
-	currentFrameIndex := 1
-	flashStartIndex := -1
-	previousBrightness := (brightnessExtTab.Front().Value.(Flash)).Brightness
-	for brightnessExtremeElement := brightnessExtTab.Front(); brightnessExtremeElement != nil; brightnessExtremeElement = brightnessExtremeElement.Next() {
-
-		brightnessExtreme := brightnessExtremeElement.Value.(Flash)
-
-		currentFrameIndex += brightnessExtreme.Frames
-		currentBrightness := brightnessExtreme.Brightness
-		currentBrightnessAbs := int(math.Abs(float64(currentBrightness)))
-
-		var darkerBrightness int
-		if previousBrightness < 0 {
-			darkerBrightness = int(math.Abs(float64(previousBrightness)))
-		} else {
-			darkerBrightness = currentBrightnessAbs
+	t.lastTimestamp = accumulation.Timestamp
+
+	//Signs are equal, trend continues
+	if (brightness < 0) == (t.trend.Brightness < 0) {
+		t.trend.Frames++
+		t.trend.End = accumulation.Index
+		if accumulation.Brightness != 0 && accumulation.Darker < t.trend.Darker {
+			t.trend.Darker = accumulation.Darker
 		}
-
-		//We are currently checking for flashes
-		if flashStartIndex != -1 {
-			frameCounter += brightnessExtreme.Frames
+		if math.Abs(float64(t.trend.Brightness)) < math.Abs(float64(brightness)) {
+			t.trend.Brightness = brightness
+			t.trend.Peak, t.trend.PeakTime = accumulation.Index, accumulation.Timestamp
 		}
+		return Flash{}, false
+	}
 
-		//Has to be a difference of 20 or more candellas, and darker frame must be below 160
-		if currentBrightnessAbs >= 20 && darkerBrightness < 160 {
-			if flashStartIndex == -1 {
-				//Start detecting flashes
-				flashStartIndex = currentFrameIndex
-			}
-			countedFlashes++
-		}
-
-		//We have surpassed 1 second after checking for flashes, check to see if we need to make a report
-		if frameCounter >= fps || brightnessExtremeElement.Next() == nil {
-
-			//Crossed threshold
-			if countedFlashes >= flashesPerSecondThreshold {
-				var hazard hazards.Hazard
-				hazard.Start = uint(float64(flashStartIndex) / float64(fps))
-				hazard.End = uint(float64(currentFrameIndex) / float64(fps))
-				hazard.HazardType = "Flash"
-				hazardReport.Hazards.PushBack(hazard)
-			}
-
-			//Reset
-			flashStartIndex = -1
-			frameCounter = 0
-			countedFlashes = 0
-		}
+	//Inversion occured
+	flash := t.trend
+	flash.EndTime = accumulation.Timestamp
+	t.trend = newTrend(accumulation)
+	return flash, true
+}
 
-		previousBrightness = currentBrightness
+//finish ends the trend in progress, false when there were no accumulations
+func (t *flashTracker) finish() (Flash, bool) {
+	if !t.started {
+		return Flash{}, false
 	}
 
-	hazardReport.Hazards = consolidateHazardList(hazardReport.Hazards)
-
-	return hazardReport
+	flash := t.trend
+	//The last frame is assumed to be shown as long as the one before it
+	flash.EndTime = t.lastTimestamp + t.lastDuration
+	t.started = false
+	return flash, true
 }
 
-//consolidateHazardList compresses consecutive entries down into a hazard
-//consecutive entries are hazards where the previous hazard end is equal to the current hazard start
-//an example of a consecutive entry:
-//hazard1 {Start=0; End=3}, hazard2 {Start=3; End=6} = hazard {Start=0; End=6}
-func consolidateHazardList(li hazards.HazardList) hazards.HazardList {
-
-	if (li.Front()) == nil {
-		return li
-	}
+//newTrend starts a brightness trend at accumulation
+func newTrend(accumulation BrightnessAccumulation) Flash {
+	var trend Flash
+	trend.Frames = 1
+	trend.Brightness = accumulation.Accumulation
+	trend.Start, trend.End = accumulation.Index, accumulation.Index
+	trend.StartTime = accumulation.Timestamp
+	trend.Peak, trend.PeakTime = accumulation.Index, accumulation.Timestamp
+	trend.Darker = accumulation.Darker
+	return trend
+}
 
-	var consolidated hazards.HazardList
+//createFlashEvent keeps an inversion from the flash table if it is strong enough to be half of a flash
+func createFlashEvent(flash Flash, luminance profiles.LuminanceRules) (flashEvent, bool) {
+	var event flashEvent
 
-	ele := li.Front()
-	val := ele.Value.(hazards.Hazard)
+	currentBrightness := flash.Brightness
+	currentBrightnessAbs := float32(math.Abs(float64(currentBrightness)))
 
-	var temp hazards.Hazard
-	temp.Start = val.Start
-	temp.End = val.End
-	temp.HazardType = val.HazardType
+	//Has to be a big enough difference in candellas, and darker frame must be dark enough
+	if currentBrightnessAbs < luminance.FlashDelta || float32(flash.Darker) >= luminance.DarkBrightnessMax {
+		return event, false
+	}
 
-	for ele = ele.Next(); ele != nil; ele = ele.Next() {
-		val = ele.Value.(hazards.Hazard)
+	event.Frame = flash.Peak
+	event.Time = flash.PeakTime
+	event.Direction = 1
+	if currentBrightness < 0 {
+		event.Direction = -1
+	}
+	return event, true
+}
 
-		if val.Start == temp.End {
-			temp.End = val.End
-		} else {
-			consolidated.PushBack(temp)
-			temp = hazards.Hazard{}
-			temp.Start = val.Start
-			temp.End = val.End
-			temp.HazardType = val.HazardType
-		}
+//frameTime calculates the presentation time of the frame at index
+func frameTime(index uint, fps int) time.Duration {
+	if fps <= 0 {
+		return 0
 	}
-	consolidated.PushBack(temp)
-	return consolidated
+	return time.Duration(index) * time.Second / time.Duration(fps)
 }
@@ -0,0 +1,591 @@
+package processors
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lycerius/epilguard/hazards"
+	"github.com/lycerius/epilguard/profiles"
+)
+
+//Size of every chart of the HTML report and of the area inside its axes
+const (
+	_ChartWidth      = 960
+	_ChartHeight     = 220
+	_ChartLeft       = 56
+	_ChartTop        = 12
+	_ChartPlotWidth  = _ChartWidth - _ChartLeft - 16
+	_ChartPlotHeight = _ChartHeight - _ChartTop - 28
+)
+
+//Most time ticks along the bottom of a chart
+const _ChartTicksMax = 10
+
+//Steps between time ticks in seconds, the first that keeps the ticks under _ChartTicksMax is used
+var chartTickSteps = []int{1, 2, 5, 10, 15, 30, 60, 120, 300, 600, 900, 1800, 3600}
+
+//Timeline is what the brightness of a video did over time, it is charted by the HTML report.
+//Both series are downsampled to the lowest, highest and last value in stretches of time no wider than a column of the chart
+type Timeline struct {
+	Accumulation        []TimelinePoint //Brightness accumulation of the frames
+	FlashFrequency      []TimelinePoint //Flashes in the second up to every point, it changes as transitions come and go
+	FlashesPerSecondMax int             //Most flashes allowed in any one second
+}
+
+//TimelinePoint is a value at a presentation time
+type TimelinePoint struct {
+	Time  time.Duration
+	Value int
+}
+
+//timelineSeries downsamples a series as its points arrive in time order, keeping the lowest, highest and last point
+//of every stretch of time span long. The span doubles whenever there are more stretches than a chart has room for
+type timelineSeries struct {
+	span    time.Duration
+	buckets []timelineBucket
+}
+
+//timelineBucket is the lowest, highest and last point of the stretch of time at index
+type timelineBucket struct {
+	index             int64
+	low, high, latest TimelinePoint
+}
+
+//add records the next point of the series
+func (s *timelineSeries) add(point TimelinePoint) {
+	if s.span <= 0 {
+		s.span = time.Millisecond
+	}
+
+	index := int64(point.Time / s.span)
+	last := len(s.buckets) - 1
+	if last >= 0 && s.buckets[last].index == index {
+		s.buckets[last].merge(timelineBucket{index, point, point, point})
+		return
+	}
+	s.buckets = append(s.buckets, timelineBucket{index, point, point, point})
+
+	//Two stretches to a column, so every column of the chart still gets its own lowest and highest point
+	for len(s.buckets) > 2*_ChartPlotWidth {
+		s.span *= 2
+		merged := s.buckets[:0]
+		for _, bucket := range s.buckets {
+			bucket.index /= 2
+			if len(merged) > 0 && merged[len(merged)-1].index == bucket.index {
+				merged[len(merged)-1].merge(bucket)
+			} else {
+				merged = append(merged, bucket)
+			}
+		}
+		s.buckets = merged
+	}
+}
+
+//merge takes the points of the later bucket other into b
+func (b *timelineBucket) merge(other timelineBucket) {
+	if other.low.Value < b.low.Value {
+		b.low = other.low
+	}
+	if other.high.Value > b.high.Value {
+		b.high = other.high
+	}
+	b.latest = other.latest
+}
+
+//points returns the points kept of every stretch in time order
+func (s *timelineSeries) points() []TimelinePoint {
+	var points []TimelinePoint
+	for _, bucket := range s.buckets {
+		kept := []TimelinePoint{bucket.low, bucket.high, bucket.latest}
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].Time < kept[j].Time })
+		for _, point := range kept {
+			if len(points) == 0 || points[len(points)-1] != point {
+				points = append(points, point)
+			}
+		}
+	}
+	return points
+}
+
+//timelineRecorder builds the timeline of a video as its frames and flashes arrive.
+//Only the flashes of the last second are kept to count the flash frequency
+type timelineRecorder struct {
+	luminance           profiles.LuminanceRules
+	flashesPerSecondMax int
+	accumulation        timelineSeries
+	frequency           timelineSeries
+	flashes             int             //Flashes in the second up to the last change counted
+	events              []flashEvent    //Transitions changes still to be counted could include, in time order
+	changes             []time.Duration //Times transitions enter or leave the count that are still to be counted, in order
+}
+
+//newTimelineRecorder creates a recorder counting the flashes profile describes
+func newTimelineRecorder(profile profiles.Profile) *timelineRecorder {
+	var recorder timelineRecorder
+	recorder.luminance = profile.Luminance
+	recorder.flashesPerSecondMax = profile.Frequency.FlashesPerSecondMax
+	recorder.frequency.add(TimelinePoint{0, 0})
+	return &recorder
+}
+
+//add records the accumulation of a frame and the flashes it ended
+func (r *timelineRecorder) add(accumulation BrightnessAccumulation, flashes []Flash) {
+	r.accumulation.add(TimelinePoint{accumulation.Timestamp, accumulation.Accumulation})
+	r.addFlashes(flashes)
+}
+
+//addFlashes records the flashes strong enough to be half of a flash, flashes arrive in the order they end
+func (r *timelineRecorder) addFlashes(flashes []Flash) {
+	for _, flash := range flashes {
+		event, ok := createFlashEvent(flash, r.luminance)
+		if !ok {
+			continue
+		}
+
+		//Changes before the transition can't include it or any later one
+		r.countChanges(event.Time, false)
+		r.events = append(r.events, event)
+
+		//Leaving times of earlier transitions can come after this one's
+		for _, change := range []time.Duration{event.Time, event.Time + time.Second} {
+			position := len(r.changes)
+			for position > 0 && r.changes[position-1] > change {
+				position--
+			}
+			r.changes = append(r.changes, 0)
+			copy(r.changes[position+1:], r.changes[position:])
+			r.changes[position] = change
+		}
+	}
+}
+
+//countChanges counts the flashes in the second up to every change before until, or every change when all is set
+func (r *timelineRecorder) countChanges(until time.Duration, all bool) {
+	counted := 0
+	for ; counted < len(r.changes) && (all || r.changes[counted] < until); counted++ {
+		change := r.changes[counted]
+
+		next := 0
+		for next < len(r.events) && r.events[next].Time <= change {
+			next++
+		}
+
+		flashes := countFlashes(eventsBefore(r.events[:next], change))
+		if flashes != r.flashes {
+			r.flashes = flashes
+			r.frequency.add(TimelinePoint{change, flashes})
+		}
+
+		//Transitions a second or more before the change have left the count for good
+		forget := 0
+		for forget < len(r.events) && change-r.events[forget].Time >= time.Second {
+			forget++
+		}
+		r.events = r.events[forget:]
+	}
+	r.changes = r.changes[counted:]
+}
+
+//finish returns the timeline, counting the changes that are left
+func (r *timelineRecorder) finish() Timeline {
+	r.countChanges(0, true)
+
+	var timeline Timeline
+	timeline.Accumulation = r.accumulation.points()
+	timeline.FlashFrequency = r.frequency.points()
+	timeline.FlashesPerSecondMax = r.flashesPerSecondMax
+	return timeline
+}
+
+//htmlReport is what the HTML report template shows
+type htmlReport struct {
+	Video     string
+	CreatedOn string
+	Profile   string
+	Charts    []htmlChart
+	Hazards   []htmlHazard
+}
+
+//htmlChart is an SVG chart of a series over the length of the video with the hazards shaded
+type htmlChart struct {
+	Title                    string
+	Duration                 float64 //Seconds the chart spans
+	Width, Height            int
+	Left, Top, Right, Bottom int //Edges of the plot
+	PlotHeight               int
+	Trace                    string //Points of the polyline
+	Limit                    string //Y of the dashed line of the most allowed, empty when there is none
+	Hazards                  []htmlRegion
+	XTicks                   []htmlTick
+	YTicks                   []htmlTick
+}
+
+//htmlRegion shades the time a hazard spans on a chart
+type htmlRegion struct {
+	Index    int
+	Class    string
+	X, Width string
+	Title    string
+}
+
+//htmlTick is a labelled position along an axis
+type htmlTick struct {
+	Position string
+	Label    string
+}
+
+//htmlHazard is a row of the hazard table
+type htmlHazard struct {
+	Index        int
+	Class        string
+	Hazard       hazards.Hazard
+	Duration     string
+	Evidence     []template.URL
+	ContactSheet template.URL
+}
+
+//chartScale maps times and values to the plot area of a chart
+type chartScale struct {
+	duration        time.Duration
+	lowest, highest int
+}
+
+//ExportHTMLReport creates a self contained html page charting timeline and report at csvDir using the name of the video at path and the current time date.
+//Evidence frames and contact sheets of the hazards are embedded, so the page can be opened offline or sent on its own
+func ExportHTMLReport(path, csvDir string, report hazards.HazardReport, timeline Timeline, date time.Time) error {
+	var page htmlReport
+	page.Video = filepath.Base(path)
+	page.CreatedOn = report.CreatedOn.Format(time.RFC1123)
+	page.Profile = report.Profile
+
+	var duration time.Duration
+	if len(timeline.Accumulation) > 0 {
+		duration = timeline.Accumulation[len(timeline.Accumulation)-1].Time
+	}
+
+	index := 0
+	for hazardElement := report.Hazards.Front(); hazardElement != nil; hazardElement = hazardElement.Next() {
+		hazard := hazardElement.Value.(hazards.Hazard)
+		index++
+
+		row, err := newHTMLHazard(index, hazard)
+		if err != nil {
+			return err
+		}
+		page.Hazards = append(page.Hazards, row)
+
+		if end := time.Duration(hazard.EndTime * float64(time.Second)); end > duration {
+			duration = end
+		}
+	}
+
+	if duration <= 0 {
+		duration = time.Second
+	}
+
+	page.Charts = []htmlChart{
+		newAccumulationChart(timeline, page.Hazards, duration),
+		newFrequencyChart(timeline, page.Hazards, duration),
+	}
+
+	return writeFile(generateExportItemFileName(path, csvDir, "Report", date)+".html", func(file *bufio.Writer) error {
+		return htmlReportTemplate.Execute(file, page)
+	})
+}
+
+//newHTMLHazard creates the table row of hazard, embedding its evidence frames and contact sheet if it has them
+func newHTMLHazard(index int, hazard hazards.Hazard) (htmlHazard, error) {
+	var row htmlHazard
+	row.Index = index
+	row.Class = strings.ToLower(hazard.HazardType)
+	row.Hazard = hazard
+	row.Duration = strconv.FormatFloat(hazard.Duration, 'f', 2, 64)
+
+	for _, evidence := range hazard.Evidence {
+		image, err := embedPNG(evidence)
+		if err != nil {
+			return row, err
+		}
+		row.Evidence = append(row.Evidence, image)
+	}
+
+	if hazard.ContactSheet != "" {
+		image, err := embedPNG(hazard.ContactSheet)
+		if err != nil {
+			return row, err
+		}
+		row.ContactSheet = image
+	}
+
+	return row, nil
+}
+
+//embedPNG reads the PNG at path into a data URL
+func embedPNG(path string) (template.URL, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(contents)), nil
+}
+
+//newAccumulationChart charts the brightness accumulation of every frame, symmetric around 0
+func newAccumulationChart(timeline Timeline, rows []htmlHazard, duration time.Duration) htmlChart {
+	highest := 1
+	for _, point := range timeline.Accumulation {
+		if point.Value > highest {
+			highest = point.Value
+		} else if -point.Value > highest {
+			highest = -point.Value
+		}
+	}
+
+	scale := chartScale{duration, -highest, highest}
+	chart := newChart("Brightness accumulation", scale, rows)
+	chart.Trace = traceColumns(timeline.Accumulation, scale)
+	chart.YTicks = []htmlTick{scale.yTick(highest), scale.yTick(0), scale.yTick(-highest)}
+	return chart
+}
+
+//newFrequencyChart charts the flashes in the second up to every moment against the most allowed
+func newFrequencyChart(timeline Timeline, rows []htmlHazard, duration time.Duration) htmlChart {
+	highest := timeline.FlashesPerSecondMax + 1
+	for _, point := range timeline.FlashFrequency {
+		if point.Value > highest {
+			highest = point.Value
+		}
+	}
+
+	scale := chartScale{duration, 0, highest}
+	chart := newChart("Flashes per second", scale, rows)
+	chart.Trace = traceSteps(timeline.FlashFrequency, scale)
+	chart.Limit = formatChartNumber(scale.y(timeline.FlashesPerSecondMax))
+	chart.YTicks = []htmlTick{scale.yTick(highest), scale.yTick(timeline.FlashesPerSecondMax), scale.yTick(0)}
+	return chart
+}
+
+//newChart creates a chart titled title with time ticks and the hazards of rows shaded
+func newChart(title string, scale chartScale, rows []htmlHazard) htmlChart {
+	var chart htmlChart
+	chart.Title = title
+	chart.Duration = scale.duration.Seconds()
+	chart.Width, chart.Height = _ChartWidth, _ChartHeight
+	chart.Left, chart.Top = _ChartLeft, _ChartTop
+	chart.Right, chart.Bottom = _ChartLeft+_ChartPlotWidth, _ChartTop+_ChartPlotHeight
+	chart.PlotHeight = _ChartPlotHeight
+
+	for _, row := range rows {
+		start := scale.x(time.Duration(row.Hazard.StartTime * float64(time.Second)))
+		end := scale.x(time.Duration(row.Hazard.EndTime * float64(time.Second)))
+		width := end - start
+		if width < 1 {
+			width = 1
+		}
+
+		var region htmlRegion
+		region.Index = row.Index
+		region.Class = row.Class
+		region.X = formatChartNumber(start)
+		region.Width = formatChartNumber(width)
+		region.Title = fmt.Sprintf("%d. %s %s - %s", row.Index, row.Hazard.HazardType, row.Hazard.StartTimecode, row.Hazard.EndTimecode)
+		chart.Hazards = append(chart.Hazards, region)
+	}
+
+	step := chartTickSteps[len(chartTickSteps)-1]
+	for _, candidate := range chartTickSteps {
+		if scale.duration <= time.Duration(candidate*_ChartTicksMax)*time.Second {
+			step = candidate
+			break
+		}
+	}
+
+	for tick := time.Duration(0); tick <= scale.duration; tick += time.Duration(step) * time.Second {
+		chart.XTicks = append(chart.XTicks, htmlTick{formatChartNumber(scale.x(tick)), formatClock(tick)})
+	}
+
+	return chart
+}
+
+//traceColumns draws series as a polyline with the lowest and highest value of every column of the plot,
+//so a long video stays small without losing short spikes
+func traceColumns(series []TimelinePoint, scale chartScale) string {
+	var trace strings.Builder
+	column := -1
+	var low, high int
+
+	flush := func() {
+		if column < 0 {
+			return
+		}
+		x := formatChartNumber(float64(_ChartLeft + column))
+		fmt.Fprintf(&trace, "%s,%s ", x, formatChartNumber(scale.y(low)))
+		if high != low {
+			fmt.Fprintf(&trace, "%s,%s ", x, formatChartNumber(scale.y(high)))
+		}
+	}
+
+	for _, point := range series {
+		pointColumn := int(scale.x(point.Time)) - _ChartLeft
+		if pointColumn != column {
+			flush()
+			column, low, high = pointColumn, point.Value, point.Value
+		} else if point.Value < low {
+			low = point.Value
+		} else if point.Value > high {
+			high = point.Value
+		}
+	}
+	flush()
+
+	return strings.TrimSpace(trace.String())
+}
+
+//traceSteps draws series as a polyline that holds every value until the next one, up to the end of the chart
+func traceSteps(series []TimelinePoint, scale chartScale) string {
+	var trace strings.Builder
+	for i, point := range series {
+		x := formatChartNumber(scale.x(point.Time))
+		if i > 0 {
+			fmt.Fprintf(&trace, "%s,%s ", x, formatChartNumber(scale.y(series[i-1].Value)))
+		}
+		fmt.Fprintf(&trace, "%s,%s ", x, formatChartNumber(scale.y(point.Value)))
+	}
+
+	if len(series) > 0 {
+		last := series[len(series)-1].Value
+		fmt.Fprintf(&trace, "%s,%s", formatChartNumber(scale.x(scale.duration)), formatChartNumber(scale.y(last)))
+	}
+
+	return strings.TrimSpace(trace.String())
+}
+
+//x is where t falls across the plot
+func (s chartScale) x(t time.Duration) float64 {
+	if t > s.duration {
+		t = s.duration
+	}
+	return _ChartLeft + float64(t)/float64(s.duration)*_ChartPlotWidth
+}
+
+//y is where value falls down the plot
+func (s chartScale) y(value int) float64 {
+	return _ChartTop + float64(s.highest-value)/float64(s.highest-s.lowest)*_ChartPlotHeight
+}
+
+//yTick labels value on the vertical axis
+func (s chartScale) yTick(value int) htmlTick {
+	return htmlTick{formatChartNumber(s.y(value)), strconv.Itoa(value)}
+}
+
+//formatChartNumber formats a chart coordinate
+func formatChartNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', 1, 64)
+}
+
+//formatClock formats t as minutes and seconds, with hours once t reaches an hour
+func formatClock(t time.Duration) string {
+	seconds := int(t / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+//htmlReportTemplate lays out the HTML report, everything it needs is inline so it works offline
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Epilguard report for {{.Video}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; margin-bottom: 0.2em; }
+.summary { color: #555; margin-top: 0; }
+svg.chart { display: block; width: 100%; max-width: 960px; margin: 1em 0; background: #fafafa; border: 1px solid #ddd; }
+svg.chart .trace { fill: none; stroke: #1f5fa8; stroke-width: 1; }
+svg.chart .limit { stroke: #c00; stroke-dasharray: 6 4; }
+svg.chart .axis { stroke: #999; }
+svg.chart text { font-size: 11px; fill: #555; }
+svg.chart .cursor { stroke: #444; visibility: hidden; }
+svg.chart:hover .cursor { visibility: visible; }
+.hazard { fill: #e33; fill-opacity: 0.2; }
+.hazard.extendedflash { fill: #f90; }
+.hazard.redflash { fill: #b0f; }
+.hazard.pattern { fill: #0a8; }
+.hazard.active { fill-opacity: 0.5; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 0.4em 0.6em; text-align: left; vertical-align: top; }
+tr.active { background: #fee; }
+td img { height: 72px; margin-right: 4px; border: 1px solid #ccc; }
+details img { height: auto; max-width: 960px; }
+</style>
+</head>
+<body>
+<h1>{{.Video}}</h1>
+<p class="summary">{{len .Hazards}} hazard(s) found with the {{.Profile}} profile, {{.CreatedOn}}</p>
+{{range $chart := .Charts}}
+<h2>{{.Title}}</h2>
+<svg class="chart" viewBox="0 0 {{.Width}} {{.Height}}" data-duration="{{.Duration}}" data-left="{{.Left}}" data-right="{{.Right}}">
+{{range .Hazards}}<rect class="hazard {{.Class}}" data-hazard="{{.Index}}" x="{{.X}}" y="{{$chart.Top}}" width="{{.Width}}" height="{{$chart.PlotHeight}}"><title>{{.Title}}</title></rect>
+{{end}}<line class="axis" x1="{{.Left}}" y1="{{.Bottom}}" x2="{{.Right}}" y2="{{.Bottom}}"/>
+<line class="axis" x1="{{.Left}}" y1="{{.Top}}" x2="{{.Left}}" y2="{{.Bottom}}"/>
+{{range .XTicks}}<text x="{{.Position}}" y="{{$chart.Height}}" dy="-6" text-anchor="middle">{{.Label}}</text>
+{{end}}{{range .YTicks}}<text x="{{$chart.Left}}" dx="-6" y="{{.Position}}" text-anchor="end" dominant-baseline="middle">{{.Label}}</text>
+{{end}}{{if .Limit}}<line class="limit" x1="{{.Left}}" y1="{{.Limit}}" x2="{{.Right}}" y2="{{.Limit}}"/>
+{{end}}<polyline class="trace" points="{{.Trace}}"/>
+<line class="cursor" x1="{{.Left}}" y1="{{.Top}}" x2="{{.Left}}" y2="{{.Bottom}}"/>
+<text class="readout" x="{{.Right}}" dx="-4" y="{{.Top}}" dy="12" text-anchor="end"></text>
+</svg>
+{{end}}
+<h2>Hazards</h2>
+{{if .Hazards}}<table>
+<tr><th>#</th><th>Type</th><th>Start</th><th>End</th><th>Duration (s)</th><th>Frames</th><th>Evidence</th></tr>
+{{range .Hazards}}<tr data-hazard="{{.Index}}">
+<td>{{.Index}}</td>
+<td>{{.Hazard.HazardType}}</td>
+<td>{{.Hazard.StartTimecode}}</td>
+<td>{{.Hazard.EndTimecode}}</td>
+<td>{{.Duration}}</td>
+<td>{{.Hazard.StartFrame}} - {{.Hazard.EndFrame}}</td>
+<td>{{range .Evidence}}<img src="{{.}}" alt="Evidence frame">{{end}}{{if .ContactSheet}}<details><summary>Contact sheet</summary><img src="{{.ContactSheet}}" alt="Contact sheet"></details>{{end}}</td>
+</tr>
+{{end}}</table>{{else}}<p>No hazards were found.</p>{{end}}
+<script>
+function highlight(hazard, active) {
+	document.querySelectorAll('[data-hazard="' + hazard + '"]').forEach(function (element) {
+		element.classList.toggle('active', active);
+	});
+}
+
+document.querySelectorAll('[data-hazard]').forEach(function (element) {
+	var hazard = element.getAttribute('data-hazard');
+	element.addEventListener('mouseenter', function () { highlight(hazard, true); });
+	element.addEventListener('mouseleave', function () { highlight(hazard, false); });
+});
+
+document.querySelectorAll('svg.chart').forEach(function (chart) {
+	var cursor = chart.querySelector('.cursor');
+	var readout = chart.querySelector('.readout');
+	var left = parseFloat(chart.dataset.left), right = parseFloat(chart.dataset.right), duration = parseFloat(chart.dataset.duration);
+
+	chart.addEventListener('mousemove', function (event) {
+		var box = chart.getBoundingClientRect();
+		var x = (event.clientX - box.left) * chart.viewBox.baseVal.width / box.width;
+		x = Math.min(Math.max(x, left), right);
+		cursor.setAttribute('x1', x);
+		cursor.setAttribute('x2', x);
+		readout.textContent = ((x - left) / (right - left) * duration).toFixed(2) + 's';
+	});
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,325 @@
+package processors
+
+import (
+	"container/list"
+	"math"
+	"time"
+
+	"github.com/lycerius/epilguard/decoder"
+	"github.com/lycerius/epilguard/equations"
+	"github.com/lycerius/epilguard/hazards"
+	"github.com/lycerius/epilguard/profiles"
+)
+
+//How many rows and columns are sampled from every frame
+const _PatternScanLines = 64
+
+//How far the spacing of stripes can drift from the average spacing while still being regular
+const _PatternRegularityTolerance = 0.25
+
+//PatternTable a list of pattern measurements
+type PatternTable = *list.List
+
+//PatternMeasurement describes the strongest regular pattern found in a frame
+type PatternMeasurement struct {
+	Index     uint
+	Timestamp time.Duration //Presentation time of the frame
+	Pairs     int           //Most light-dark pairs found in a single regular run of stripes
+	Area      float32       //Fraction of the screen covered by regular runs with too many pairs
+	mask      regionMask    //Areas of the screen covered by regular runs with too many pairs
+}
+
+//extremum is a local brightness peak or trough along a scan line
+type extremum struct {
+	Position, Brightness int
+}
+
+//lineSegment is the stretch of a scan line from Start to End
+type lineSegment struct {
+	Start, End int
+}
+
+//patternStream turns every sequence of frames with a hazardous pattern into a hazard as frames arrive,
+//only the masks of the sequence in progress are kept. Profiles that do not cover patterns never report any
+type patternStream struct {
+	profile       profiles.Profile
+	fps           int
+	start, end    PatternMeasurement //First and last frame of the sequence in progress
+	inHazard      bool
+	masks         []maskedFrame //Ordered by frame index
+	width, height int
+
+	//Finished hazards, and their heatmaps in the same order
+	found    []hazards.Hazard
+	heatmaps []regionHeatmap
+}
+
+//newPatternStream creates a stream that finds the patterns profile describes in frames of width x height
+func newPatternStream(profile profiles.Profile, fps, width, height int) *patternStream {
+	var stream patternStream
+	stream.profile = profile
+	stream.fps = fps
+	stream.width = width
+	stream.height = height
+	return &stream
+}
+
+//add takes the measurement of the next frame, returning the hazard it ended, if any
+func (s *patternStream) add(measurement PatternMeasurement) []hazards.Hazard {
+	if s.fps <= 0 || !s.profile.Pattern.Enabled {
+		return nil
+	}
+
+	hazardous := measurement.Area > s.profile.Area.Fraction && measurement.Pairs > s.profile.Pattern.PairsMax
+	if !hazardous {
+		return s.finish()
+	}
+
+	if !s.inHazard {
+		s.start = measurement
+		s.inHazard = true
+	}
+	s.end = measurement
+	if !measurement.mask.empty() {
+		s.masks = append(s.masks, maskedFrame{measurement.Index, measurement.mask})
+	}
+	return nil
+}
+
+//finish ends the sequence in progress, returning its hazard
+func (s *patternStream) finish() []hazards.Hazard {
+	if !s.inHazard {
+		return nil
+	}
+
+	hazard := newPatternHazard(s.start, s.end, s.fps)
+	heatmap := localizeHazard(&hazard, s.masks, s.width, s.height)
+	s.found = append(s.found, hazard)
+	s.heatmaps = append(s.heatmaps, heatmap)
+
+	s.inHazard = false
+	s.masks = nil
+	return []hazards.Hazard{hazard}
+}
+
+//hazardCount counts the hazards found so far, including the one still in progress
+func (s *patternStream) hazardCount() int {
+	if s.inHazard {
+		return len(s.found) + 1
+	}
+	return len(s.found)
+}
+
+//addTo adds every hazard found to report and returns their heatmaps in the same order
+func (s *patternStream) addTo(report *hazards.HazardReport) []regionHeatmap {
+	for _, hazard := range s.found {
+		report.Hazards.PushBack(hazard)
+	}
+	return s.heatmaps
+}
+
+//measurePattern samples rows and columns of a frame for runs of regular light-dark stripes.
+//Rows find vertical stripes, columns find horizontal stripes, and both find checkerboards and rings
+func measurePattern(frame decoder.Frame, profile profiles.Profile) PatternMeasurement {
+	var measurement PatternMeasurement
+	measurement.Index = frame.Index
+	measurement.Timestamp = frame.Timestamp
+
+	if frame.Width == 0 || frame.Height == 0 {
+		return measurement
+	}
+
+	pixels := frame.Pixels()
+	stride := frame.Width * 3
+
+	//Rows
+	var rowsScanned, rowLength int
+	row := make([]int, frame.Width)
+	for y := scanLineOffset(frame.Height); y < frame.Height; y += scanLineStep(frame.Height) {
+		for x := range row {
+			position := y*stride + x*3
+			row[x] = equations.RGBtoBrightness(int(pixels[position]), int(pixels[position+1]), int(pixels[position+2]))
+		}
+
+		pairs, segments := findRegularRuns(row, profile)
+		if pairs > measurement.Pairs {
+			measurement.Pairs = pairs
+		}
+		for _, segment := range segments {
+			rowLength += segment.End - segment.Start
+			for cellX := pixelToCell(segment.Start, frame.Width); cellX <= pixelToCell(segment.End, frame.Width); cellX++ {
+				measurement.mask.set(cellX, pixelToCell(y, frame.Height))
+			}
+		}
+		rowsScanned++
+	}
+
+	//Columns
+	var columnsScanned, columnLength int
+	column := make([]int, frame.Height)
+	for x := scanLineOffset(frame.Width); x < frame.Width; x += scanLineStep(frame.Width) {
+		for y := range column {
+			position := y*stride + x*3
+			column[y] = equations.RGBtoBrightness(int(pixels[position]), int(pixels[position+1]), int(pixels[position+2]))
+		}
+
+		pairs, segments := findRegularRuns(column, profile)
+		if pairs > measurement.Pairs {
+			measurement.Pairs = pairs
+		}
+		for _, segment := range segments {
+			columnLength += segment.End - segment.Start
+			for cellY := pixelToCell(segment.Start, frame.Height); cellY <= pixelToCell(segment.End, frame.Height); cellY++ {
+				measurement.mask.set(pixelToCell(x, frame.Width), cellY)
+			}
+		}
+		columnsScanned++
+	}
+
+	rowArea := float32(rowLength) / float32(rowsScanned*frame.Width)
+	columnArea := float32(columnLength) / float32(columnsScanned*frame.Height)
+	measurement.Area = float32(math.Max(float64(rowArea), float64(columnArea)))
+
+	return measurement
+}
+
+//scanLineStep is the distance between sampled scan lines over a dimension of size pixels
+func scanLineStep(size int) int {
+	step := size / _PatternScanLines
+	if step < 1 {
+		step = 1
+	}
+	return step
+}
+
+//scanLineOffset centers the sampled scan lines over a dimension of size pixels
+func scanLineOffset(size int) int {
+	return scanLineStep(size) / 2
+}
+
+//findRegularRuns finds runs of evenly spaced light-dark stripes along a scan line and returns
+//the most pairs found in one run and the segments of the line covered by runs with too many pairs
+func findRegularRuns(line []int, profile profiles.Profile) (int, []lineSegment) {
+	var maxPairs int
+	patterned := make([]lineSegment, 0)
+	extrema := findExtrema(line, int(profile.Luminance.FlashDelta))
+
+	closeRun := func(first, last int) {
+		pairs := (last - first + 1) / 2
+		if pairs > maxPairs {
+			maxPairs = pairs
+		}
+		if pairs > profile.Pattern.PairsMax {
+			patterned = append(patterned, lineSegment{extrema[first].Position, extrema[last].Position})
+		}
+	}
+
+	runStart := 0
+	var spacingSum int
+	for i := 0; i < len(extrema)-1; i++ {
+		spacing := extrema[i+1].Position - extrema[i].Position
+		contrasting := isContrastingStripe(extrema[i], extrema[i+1], profile.Luminance)
+
+		if contrasting && (i == runStart || isRegularSpacing(spacing, spacingSum, i-runStart)) {
+			spacingSum += spacing
+			continue
+		}
+
+		//Run is broken, a new one starts at this stripe if it has enough contrast
+		closeRun(runStart, i)
+		if contrasting {
+			runStart = i
+			spacingSum = spacing
+		} else {
+			runStart = i + 1
+			spacingSum = 0
+		}
+	}
+
+	if len(extrema) > 0 {
+		closeRun(runStart, len(extrema)-1)
+	}
+
+	return maxPairs, patterned
+}
+
+//isContrastingStripe checks that two neighbouring stripes differ like a flash does
+func isContrastingStripe(a, b extremum, luminance profiles.LuminanceRules) bool {
+	darker, lighter := a.Brightness, b.Brightness
+	if darker > lighter {
+		darker, lighter = lighter, darker
+	}
+	return float32(lighter-darker) >= luminance.FlashDelta && float32(darker) < luminance.DarkBrightnessMax
+}
+
+//isRegularSpacing checks that spacing is close to the average of the previous count spacings
+func isRegularSpacing(spacing, spacingSum, count int) bool {
+	average := float64(spacingSum) / float64(count)
+	tolerance := math.Max(1, average*_PatternRegularityTolerance)
+	return math.Abs(float64(spacing)-average) <= tolerance
+}
+
+//findExtrema finds alternating brightness peaks and troughs along a line that differ by at least delta
+func findExtrema(line []int, delta int) []extremum {
+	extrema := make([]extremum, 0)
+
+	if len(line) == 0 {
+		return extrema
+	}
+
+	var current extremum
+	low := extremum{0, line[0]}
+	high := extremum{0, line[0]}
+	direction := 0 //1 when searching for a peak, -1 when searching for a trough
+
+	for i, brightness := range line {
+		switch direction {
+		case 0:
+			//Wait until the line swings far enough to know which way it is going
+			if brightness < low.Brightness {
+				low = extremum{i, brightness}
+			}
+			if brightness > high.Brightness {
+				high = extremum{i, brightness}
+			}
+			if high.Brightness-low.Brightness >= delta {
+				if low.Position < high.Position {
+					extrema = append(extrema, low)
+					current = high
+					direction = 1
+				} else {
+					extrema = append(extrema, high)
+					current = low
+					direction = -1
+				}
+			}
+		case 1:
+			if brightness > current.Brightness {
+				current = extremum{i, brightness}
+			} else if current.Brightness-brightness >= delta {
+				extrema = append(extrema, current)
+				current = extremum{i, brightness}
+				direction = -1
+			}
+		case -1:
+			if brightness < current.Brightness {
+				current = extremum{i, brightness}
+			} else if brightness-current.Brightness >= delta {
+				extrema = append(extrema, current)
+				current = extremum{i, brightness}
+				direction = 1
+			}
+		}
+	}
+
+	if direction != 0 {
+		extrema = append(extrema, current)
+	}
+
+	return extrema
+}
+
+//newPatternHazard creates a pattern hazard spanning the frames measured by start to end
+func newPatternHazard(start, end PatternMeasurement, fps int) hazards.Hazard {
+	return hazards.NewHazard(hazards.PatternHazard, start.Index, end.Index, start.Timestamp, end.Timestamp+frameTime(1, fps), fps)
+}
@@ -0,0 +1,232 @@
+package processors
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/lycerius/epilguard/decoder"
+	"github.com/lycerius/epilguard/profiles"
+)
+
+//frameAnalyses chooses what the frame pipeline measures in every frame
+type frameAnalyses struct {
+	Brightness bool //Change in brightness from the previous frame
+	Red        bool //Change to or from saturated red from the previous frame
+	Pattern    bool //Regular patterns in the frame
+}
+
+//frameAnalysis is what the frame pipeline measured in a frame
+type frameAnalysis struct {
+	Index        uint
+	Timestamp    time.Duration
+	Baseline     bool                   //Whether this is the first frame, which has no frame before it to change from
+	Accumulation BrightnessAccumulation //Change in brightness, only measured by the Brightness analysis and never in the baseline
+	Red          RedTransition          //Change to or from saturated red, its Direction is 0 when there was none
+	Pattern      PatternMeasurement     //Regular patterns, only measured by the Pattern analysis
+}
+
+//frameJob is a frame on its way through the frame pipeline, jobs are handed out in frame order
+//and finish in any order, so every job has its own done channel to wait on
+type frameJob struct {
+	Index     uint
+	Timestamp time.Duration
+	Err       error //Error reading the frame, the last job carries the error that ended the stream
+
+	//Change in brightness from the previous frame, only ready once done is closed
+	AverageBrightness int
+	Darker            int
+	Mask              regionMask
+
+	//Change to or from saturated red from the previous frame, only ready once done is closed
+	RedDirection int //1 when the flash area changed to saturated red, -1 when it changed from saturated red
+	RedMask      regionMask
+
+	//Regular patterns in the frame, only ready once done is closed
+	Pattern PatternMeasurement
+
+	frame         decoder.Frame
+	previous      *frameJob
+	brightness    brightnessFrame
+	red           redFrame
+	convertedDone chan struct{} //Closed when brightness and red are ready
+	done          chan struct{} //Closed when the job is finished
+}
+
+//defaultWorkers is how many frames are worked on at once unless told otherwise
+func defaultWorkers() int {
+	return runtime.NumCPU()
+}
+
+//analyzeFrames decodes all frames and hands what analyses measured in every frame to analyzed as soon as it is measured, in frame order.
+//profile decides how much of the screen has to change for a change to count.
+//Frames are analyzed on workers goroutines at once, every frame is counted by progress
+func analyzeFrames(ctx context.Context, source decoder.FrameSource, profile profiles.Profile, analyses frameAnalyses, workers int, progress *progressTracker, analyzed func(frameAnalysis) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	jobs := startFramePipeline(ctx, source, workers, profile, analyses)
+
+	//Stop the pipeline if analyzed fails part way through
+	defer func() {
+		cancel()
+		for range jobs {
+		}
+	}()
+
+	var accBrightness int
+	baseline := true
+
+	for job := range jobs {
+		job.wait()
+
+		if job.Err != nil {
+			//This is an OK error, just EOF, as long as there was a frame
+			if job.Err == io.EOF && !baseline {
+				break
+			} else {
+				return job.Err
+			}
+		}
+
+		var analysis frameAnalysis
+		analysis.Index = job.Index
+		analysis.Timestamp = job.Timestamp
+		analysis.Baseline = baseline
+		analysis.Red = RedTransition{job.Index, job.Timestamp, job.RedDirection, job.RedMask}
+		analysis.Pattern = job.Pattern
+
+		if analyses.Brightness && !baseline {
+			averageBrightness := job.AverageBrightness
+
+			//If signs are equal, or no change, accumulate
+			if (accBrightness < 0) == (averageBrightness < 0) || averageBrightness == 0 {
+				accBrightness += averageBrightness
+			} else {
+				//If signs are different, then an inversion occured. Start new accumulation
+				accBrightness = averageBrightness
+			}
+
+			//Create new entry
+			var accumulation BrightnessAccumulation
+			accumulation.Index = job.Index
+			accumulation.Timestamp = job.Timestamp
+			accumulation.Accumulation = accBrightness
+			accumulation.Brightness = averageBrightness
+			accumulation.Darker = job.Darker
+			accumulation.mask = job.Mask
+			analysis.Accumulation = accumulation
+		}
+
+		baseline = false
+		progress.frame(job.Timestamp)
+
+		err := analyzed(analysis)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//startFramePipeline reads every frame from source and measures analyses in them on workers goroutines.
+//Jobs come out of the returned channel in frame order, the channel is closed after the job carrying the read error,
+//which is ctx.Err() if ctx is done first
+func startFramePipeline(ctx context.Context, source decoder.FrameSource, workers int, profile profiles.Profile, analyses frameAnalyses) <-chan *frameJob {
+	if workers < 1 {
+		workers = 1
+	}
+
+	//Only this many jobs can be waiting to be handled, so decoding can't run away from the analysis
+	ordered := make(chan *frameJob, workers*2)
+	pending := make(chan *frameJob, workers*2)
+
+	for i := 0; i < workers; i++ {
+		go frameWorker(pending, profile, analyses)
+	}
+
+	go func() {
+		defer close(ordered)
+		defer close(pending)
+
+		var previous *frameJob
+		for {
+			frame, err := decoder.NextFrameContext(ctx, source)
+
+			job := &frameJob{Err: err, done: make(chan struct{}), convertedDone: make(chan struct{})}
+			if err != nil {
+				close(job.convertedDone)
+				close(job.done)
+				ordered <- job
+				return
+			}
+
+			job.Index = frame.Index
+			job.Timestamp = frame.Timestamp
+			job.frame = frame
+			job.previous = previous
+			previous = job
+
+			ordered <- job
+			pending <- job
+		}
+	}()
+
+	return ordered
+}
+
+//frameWorker measures frames and compares them with the frame before them
+func frameWorker(pending <-chan *frameJob, profile profiles.Profile, analyses frameAnalyses) {
+	for job := range pending {
+		if analyses.Brightness {
+			job.brightness = rGBFrameToBrightness(job.frame)
+		}
+		if analyses.Red {
+			job.red = rGBFrameToRed(job.frame)
+		}
+		if analyses.Pattern {
+			job.Pattern = measurePattern(job.frame, profile)
+		}
+		job.frame = decoder.Frame{}
+		close(job.convertedDone)
+
+		//The first frame is only a baseline
+		if job.previous != nil {
+			<-job.previous.convertedDone
+			if analyses.Brightness {
+				compareBrightness(job, profile)
+			}
+			if analyses.Red {
+				compareRed(job, profile)
+			}
+		}
+
+		close(job.done)
+	}
+}
+
+//compareBrightness measures the change in brightness from the previous frame of job
+func compareBrightness(job *frameJob, profile profiles.Profile) {
+	difference := calculateFrameDifference(job.previous.brightness, job.brightness)
+	job.AverageBrightness, job.Darker = findAverageBrightness(difference, profile.Area)
+	if job.AverageBrightness != 0 {
+		job.Mask = createFlashMask(difference, job.AverageBrightness < 0, profile.Luminance.FlashDelta)
+	}
+}
+
+//compareRed measures the change to or from saturated red from the previous frame of job
+func compareRed(job *frameJob, profile profiles.Profile) {
+	previous := job.previous.red
+	job.RedDirection = calculateRedTransition(previous, job.red, profile.Red, profile.Area)
+	if job.RedDirection != 0 {
+		job.RedMask = createRegionMask(job.red.Width, job.red.Height, func(position int) bool {
+			return redPixelTransition(previous, job.red, position, profile.Red) == job.RedDirection
+		})
+	}
+}
+
+//wait blocks until the job is finished, then lets go of the previous frame so it can be collected
+func (job *frameJob) wait() {
+	<-job.done
+	job.previous = nil
+}
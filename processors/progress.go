@@ -0,0 +1,119 @@
+package processors
+
+import (
+	"time"
+
+	"github.com/lycerius/epilguard/decoder"
+)
+
+//How often progress is reported while frames are being analyzed
+const _ProgressInterval = time.Second
+
+//Progress describes how far along an analysis is
+type Progress struct {
+	Frames      int           //Frames analyzed so far
+	TotalFrames int           //Frames in the video, 0 when the source doesn't know
+	Position    time.Duration //Presentation time of the last frame analyzed
+	Duration    time.Duration //Length of the video, 0 when the source doesn't know
+	Percent     float32       //How much of the video has been analyzed from 0 to 100, 0 when the length is unknown
+	Elapsed     time.Duration //Time spent analyzing so far
+	ETA         time.Duration //Estimated time left, 0 when the length is unknown
+	Hazards     int           //Hazards found so far, hazards still in progress are counted as they are so far
+	Done        bool          //Whether every frame has been analyzed
+}
+
+//ProgressFunc receives progress about once a second while frames are analyzed, and once more when they are done.
+//It is called from the goroutine running the processor
+type ProgressFunc func(Progress)
+
+//progressTracker counts analyzed frames and reports them to a ProgressFunc, a nil tracker does nothing
+type progressTracker struct {
+	report     ProgressFunc
+	progress   Progress
+	started    time.Time
+	lastReport time.Time
+	hazards    func() int //Counts the hazards found so far, only called when reporting
+}
+
+//newProgressTracker creates a tracker reporting to report, nil when there is nothing to report to
+func newProgressTracker(source decoder.FrameSource, report ProgressFunc) *progressTracker {
+	if report == nil {
+		return nil
+	}
+
+	var tracker progressTracker
+	tracker.report = report
+	tracker.started = time.Now()
+	tracker.lastReport = tracker.started
+	if lengthSource, ok := source.(decoder.LengthFrameSource); ok {
+		tracker.progress.TotalFrames, tracker.progress.Duration = lengthSource.Length()
+	}
+	return &tracker
+}
+
+//countHazards sets how to count the hazards found so far
+func (t *progressTracker) countHazards(count func() int) {
+	if t == nil {
+		return
+	}
+	t.hazards = count
+}
+
+//frame counts another analyzed frame shown at timestamp
+func (t *progressTracker) frame(timestamp time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.progress.Frames++
+	t.progress.Position = timestamp
+
+	now := time.Now()
+	if now.Sub(t.lastReport) >= _ProgressInterval {
+		t.lastReport = now
+		t.send(now)
+	}
+}
+
+//finish reports that every frame was analyzed and hazards were found
+func (t *progressTracker) finish(hazards int) {
+	if t == nil {
+		return
+	}
+
+	t.hazards = nil
+	t.progress.Hazards = hazards
+	t.progress.Done = true
+	t.send(time.Now())
+}
+
+//send works out the percent and ETA and reports them
+func (t *progressTracker) send(now time.Time) {
+	progress := t.progress
+	progress.Elapsed = now.Sub(t.started)
+
+	//Frame counts are exact, durations are the next best thing
+	var fraction float64
+	if progress.TotalFrames > 0 {
+		fraction = float64(progress.Frames) / float64(progress.TotalFrames)
+	} else if progress.Duration > 0 {
+		fraction = float64(progress.Position) / float64(progress.Duration)
+	}
+	if progress.Done && (progress.TotalFrames > 0 || progress.Duration > 0) {
+		fraction = 1
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	progress.Percent = float32(fraction * 100)
+	if fraction > 0 {
+		progress.ETA = time.Duration(float64(progress.Elapsed) * (1 - fraction) / fraction)
+	}
+
+	if t.hazards != nil {
+		progress.Hazards = t.hazards()
+	}
+
+	t.report(progress)
+}
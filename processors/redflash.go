@@ -0,0 +1,220 @@
+package processors
+
+import (
+	"container/list"
+	"math"
+	"time"
+
+	"github.com/lycerius/epilguard/decoder"
+	"github.com/lycerius/epilguard/equations"
+	"github.com/lycerius/epilguard/hazards"
+	"github.com/lycerius/epilguard/profiles"
+)
+
+//redFrame Describes the red saturation and chromaticity of every pixel in a frame
+type redFrame struct {
+	Index         uint
+	Ratios        []float32
+	U, V          []float32
+	Height, Width int
+}
+
+//RedTransitionTable a list of red transitions
+type RedTransitionTable = *list.List
+
+//RedTransition describes a frame where the flash area changed to or from saturated red
+type RedTransition struct {
+	Index     uint
+	Timestamp time.Duration //Presentation time of the frame
+	Direction int           //1 when changing to saturated red, -1 when changing from saturated red
+	mask      regionMask    //Areas of the screen that changed
+}
+
+//redFlashStream finds red transitions and red flashing hazards as frames arrive.
+//Only the region masks of transitions that a hazard still in progress could span are kept
+type redFlashStream struct {
+	flashing      *flashingHazardDetector
+	lastDirection int
+	masks         []maskedFrame //Ordered by frame index
+	width, height int
+
+	//Finished hazards, and their heatmaps in the same order
+	found    []hazards.Hazard
+	heatmaps []regionHeatmap
+}
+
+//newRedFlashStream creates a stream that finds the red flashing profile describes in frames of width x height
+func newRedFlashStream(profile profiles.Profile, fps, width, height int) *redFlashStream {
+	var stream redFlashStream
+	stream.flashing = newFlashingHazardDetector(hazards.RedFlashHazard, profile.Frequency.FlashesPerSecondMax, fps)
+	stream.width = width
+	stream.height = height
+	return &stream
+}
+
+//add takes the change to or from saturated red of the next frame, returning whether it is a new transition and the hazards that can't grow any more.
+//A red flash is a pair of opposing transitions
+func (s *redFlashStream) add(change RedTransition) (bool, []hazards.Hazard) {
+	var finished []hazards.Hazard
+
+	//A transition continuing in the same direction over several frames is still one transition
+	transition := change.Direction != 0 && change.Direction != s.lastDirection
+	if transition {
+		s.lastDirection = change.Direction
+		s.masks = append(s.masks, maskedFrame{change.Index, change.mask})
+
+		var event flashEvent
+		event.Frame = change.Index
+		event.Time = change.Timestamp
+		event.Direction = change.Direction
+		finished = s.localize(s.flashing.add(event))
+	}
+
+	finished = append(finished, s.localize(s.flashing.advance(change.Timestamp))...)
+	s.forgetMasks()
+	return transition, finished
+}
+
+//finish returns the hazards that were still in progress
+func (s *redFlashStream) finish() []hazards.Hazard {
+	finished := s.localize(s.flashing.finish())
+	s.masks = nil
+	return finished
+}
+
+//localize finds the regions of the finished hazards and keeps them and their heatmaps
+func (s *redFlashStream) localize(finished []hazards.Hazard) []hazards.Hazard {
+	for i := range finished {
+		heatmap := localizeHazard(&finished[i], s.masks, s.width, s.height)
+		s.found = append(s.found, finished[i])
+		s.heatmaps = append(s.heatmaps, heatmap)
+	}
+	return finished
+}
+
+//forgetMasks drops the masks of transitions before any hazard still to be finished could start
+func (s *redFlashStream) forgetMasks() {
+	horizon, ok := s.flashing.horizon()
+	if !ok {
+		s.masks = s.masks[:0]
+		return
+	}
+
+	forget := 0
+	for forget < len(s.masks) && s.masks[forget].Index < horizon {
+		forget++
+	}
+	s.masks = s.masks[forget:]
+}
+
+//hazardCount counts the hazards found so far, including the one still in progress
+func (s *redFlashStream) hazardCount() int {
+	return len(s.found) + s.flashing.pending()
+}
+
+//addTo adds every hazard found to report and returns their heatmaps in the same order
+func (s *redFlashStream) addTo(report *hazards.HazardReport) []regionHeatmap {
+	for _, hazard := range s.found {
+		report.Hazards.PushBack(hazard)
+	}
+	return s.heatmaps
+}
+
+//rGBFrameToRed converts an RGB frame to red ratios and chromaticity coordinates
+func rGBFrameToRed(frame decoder.Frame) redFrame {
+
+	var rframe redFrame
+	rframe.Height = frame.Height
+	rframe.Width = frame.Width
+	size := frame.Height * frame.Width
+
+	ratios := make([]float32, size, size)
+	us := make([]float32, size, size)
+	vs := make([]float32, size, size)
+
+	pixels := frame.Pixels()
+	for position := 0; position < size; position++ {
+		red, green, blue := int(pixels[position*3]), int(pixels[position*3+1]), int(pixels[position*3+2])
+		ratios[position] = equations.RGBtoRedRatio(red, green, blue)
+		us[position], vs[position] = equations.RGBtoChromaticity(red, green, blue)
+	}
+
+	rframe.Index = frame.Index
+	rframe.Ratios = ratios
+	rframe.U = us
+	rframe.V = vs
+	return rframe
+}
+
+//calculateRedTransition compares two red frames and returns 1 if the flash area changed to saturated red,
+//-1 if it changed from saturated red, and 0 if neither happened
+func calculateRedTransition(f1, f2 redFrame, rules profiles.RedFlashRules, area profiles.AreaRules) int {
+	var toRed, fromRed int
+	var toRedPixels, fromRedPixels []int
+	size := f1.Height * f1.Width
+
+	//With a viewport only the pixels inside the busiest window count
+	window := newViewport(area, f1.Width, f1.Height)
+	windowed := !window.coversFrame(f1.Width, f1.Height)
+	if windowed {
+		toRedPixels = make([]int, size)
+		fromRedPixels = make([]int, size)
+	}
+
+	for i := 0; i < size; i++ {
+		pixelDirection := redPixelTransition(f1, f2, i, rules)
+
+		if pixelDirection == 0 {
+			continue
+		}
+
+		if pixelDirection == 1 {
+			toRed++
+			if windowed {
+				toRedPixels[i] = 1
+			}
+		} else {
+			fromRed++
+			if windowed {
+				fromRedPixels[i] = 1
+			}
+		}
+	}
+
+	elementsRequired := int(float32(size) * area.Fraction)
+
+	if windowed {
+		_, _, toRed = findBusiestWindow(newIntegralImage(toRedPixels, f1.Width, f1.Height), window, f1.Width, f1.Height)
+		_, _, fromRed = findBusiestWindow(newIntegralImage(fromRedPixels, f1.Width, f1.Height), window, f1.Width, f1.Height)
+		elementsRequired = int(float32(window.Width*window.Height) * area.Fraction)
+	}
+
+	if toRed >= elementsRequired && toRed >= fromRed {
+		return 1
+	} else if fromRed >= elementsRequired {
+		return -1
+	}
+	return 0
+}
+
+//redPixelTransition returns 1 if the pixel at position changed to saturated red, -1 if it changed from saturated red,
+//and 0 if neither happened or the color did not change enough
+func redPixelTransition(f1, f2 redFrame, position int, rules profiles.RedFlashRules) int {
+	wasRed := f1.Ratios[position] >= rules.SaturatedRatio
+	isRed := f2.Ratios[position] >= rules.SaturatedRatio
+
+	if wasRed == isRed {
+		return 0
+	}
+
+	du := float64(f2.U[position] - f1.U[position])
+	dv := float64(f2.V[position] - f1.V[position])
+	if float32(math.Sqrt(du*du+dv*dv)) <= rules.ChromaticityDelta {
+		return 0
+	}
+
+	if isRed {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,244 @@
+package processors
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lycerius/epilguard/hazards"
+)
+
+//How many cells the region grid has across and down every frame
+const _RegionGridSize = 32
+
+//How much of a cell has to change for the cell to take part in a flash
+const _RegionCellFraction = 0.25
+
+//regionMask marks the cells of a coarse grid over the frame that took part in a flash
+type regionMask [_RegionGridSize * _RegionGridSize / 64]uint64
+
+//maskedFrame is the region mask of a frame
+type maskedFrame struct {
+	Index uint
+	Mask  regionMask
+}
+
+//regionHeatmap counts how many frames of a hazard every cell took part in
+type regionHeatmap struct {
+	Counts        [_RegionGridSize * _RegionGridSize]int
+	Width, Height int //Size of the analyzed frames
+}
+
+//set marks the cell at cellX, cellY
+func (m *regionMask) set(cellX, cellY int) {
+	cell := cellY*_RegionGridSize + cellX
+	m[cell/64] |= 1 << uint(cell%64)
+}
+
+//has checks if the cell at index cell is marked
+func (m *regionMask) has(cell int) bool {
+	return m[cell/64]&(1<<uint(cell%64)) != 0
+}
+
+//empty checks if no cells are marked
+func (m *regionMask) empty() bool {
+	for _, word := range m {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//createRegionMask marks every cell where enough of the pixels changed, changed reports if the pixel at position did
+func createRegionMask(width, height int, changed func(position int) bool) regionMask {
+	var mask regionMask
+	var totals, changes [_RegionGridSize * _RegionGridSize]int
+
+	for y := 0; y < height; y++ {
+		cellY := y * _RegionGridSize / height
+		for x := 0; x < width; x++ {
+			cell := cellY*_RegionGridSize + x*_RegionGridSize/width
+			totals[cell]++
+			if changed(y*width + x) {
+				changes[cell]++
+			}
+		}
+	}
+
+	for cell, total := range totals {
+		if total > 0 && float32(changes[cell]) >= float32(total)*_RegionCellFraction {
+			mask.set(cell%_RegionGridSize, cell/_RegionGridSize)
+		}
+	}
+
+	return mask
+}
+
+//cellToPixel is the first pixel of a cell along a dimension of size pixels
+func cellToPixel(cell, size int) int {
+	return (cell*size + _RegionGridSize - 1) / _RegionGridSize
+}
+
+//pixelToCell is the cell a pixel falls in along a dimension of size pixels
+func pixelToCell(pixel, size int) int {
+	return pixel * _RegionGridSize / size
+}
+
+//localizeHazard finds the regions of hazard from the masks of the frames it spans, masks must be ordered by frame index
+func localizeHazard(hazard *hazards.Hazard, masks []maskedFrame, width, height int) regionHeatmap {
+	var heatmap regionHeatmap
+	heatmap.Width = width
+	heatmap.Height = height
+
+	first := sort.Search(len(masks), func(i int) bool { return masks[i].Index >= hazard.StartFrame })
+	for i := first; i < len(masks) && masks[i].Index <= hazard.EndFrame; i++ {
+		heatmap.add(masks[i].Mask)
+	}
+
+	hazard.Regions = heatmap.regions()
+	return heatmap
+}
+
+//add counts every cell marked in mask
+func (h *regionHeatmap) add(mask regionMask) {
+	for cell := range h.Counts {
+		if mask.has(cell) {
+			h.Counts[cell]++
+		}
+	}
+}
+
+//regions finds the bounding box of every connected group of cells that took part in the hazard
+func (h *regionHeatmap) regions() []hazards.Region {
+	regions := make([]hazards.Region, 0)
+	var visited [_RegionGridSize * _RegionGridSize]bool
+
+	for start := range h.Counts {
+		if h.Counts[start] == 0 || visited[start] {
+			continue
+		}
+
+		//Flood fill the group, growing its bounding box
+		minX, minY := _RegionGridSize, _RegionGridSize
+		maxX, maxY := -1, -1
+		pending := []int{start}
+		visited[start] = true
+
+		for len(pending) > 0 {
+			cell := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			cellX, cellY := cell%_RegionGridSize, cell/_RegionGridSize
+
+			if cellX < minX {
+				minX = cellX
+			}
+			if cellX > maxX {
+				maxX = cellX
+			}
+			if cellY < minY {
+				minY = cellY
+			}
+			if cellY > maxY {
+				maxY = cellY
+			}
+
+			neighbours := [][2]int{{cellX - 1, cellY}, {cellX + 1, cellY}, {cellX, cellY - 1}, {cellX, cellY + 1}}
+			for _, neighbour := range neighbours {
+				if neighbour[0] < 0 || neighbour[0] >= _RegionGridSize || neighbour[1] < 0 || neighbour[1] >= _RegionGridSize {
+					continue
+				}
+				next := neighbour[1]*_RegionGridSize + neighbour[0]
+				if h.Counts[next] > 0 && !visited[next] {
+					visited[next] = true
+					pending = append(pending, next)
+				}
+			}
+		}
+
+		var region hazards.Region
+		region.X = cellToPixel(minX, h.Width)
+		region.Y = cellToPixel(minY, h.Height)
+		region.Width = cellToPixel(maxX+1, h.Width) - region.X
+		region.Height = cellToPixel(maxY+1, h.Height) - region.Y
+		regions = append(regions, region)
+	}
+
+	return regions
+}
+
+//image draws the heatmap at the size of the analyzed frames, cells that never took part are transparent
+//and the rest go from red to yellow the more often they took part
+func (h *regionHeatmap) image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, h.Width, h.Height))
+
+	var most int
+	for _, count := range h.Counts {
+		if count > most {
+			most = count
+		}
+	}
+
+	if most == 0 {
+		return img
+	}
+
+	for y := 0; y < h.Height; y++ {
+		cellY := pixelToCell(y, h.Height)
+		for x := 0; x < h.Width; x++ {
+			count := h.Counts[cellY*_RegionGridSize+pixelToCell(x, h.Width)]
+			if count == 0 {
+				continue
+			}
+			heat := float32(count) / float32(most)
+			img.SetNRGBA(x, y, color.NRGBA{255, uint8(255 * heat), 0, uint8(96 + 159*heat)})
+		}
+	}
+
+	return img
+}
+
+//exportHeatmaps writes a heatmap PNG for every hazard in report and records its path in the hazard,
+//heatmaps must be in the same order as the hazards
+func exportHeatmaps(path, csvDir string, report hazards.HazardReport, heatmaps []regionHeatmap, date time.Time) error {
+	hazardIndex := 0
+
+	for hazardElement := report.Hazards.Front(); hazardElement != nil; hazardElement = hazardElement.Next() {
+		hazard := hazardElement.Value.(hazards.Hazard)
+		heatmap := heatmaps[hazardIndex]
+		hazardIndex++
+
+		datasetName := "Heatmap-" + hazard.HazardType + "-" + strconv.Itoa(hazardIndex)
+		fileName, err := exportPNG(path, csvDir, datasetName, heatmap.image(), date)
+		if err != nil {
+			return err
+		}
+
+		hazard.Heatmap = fileName
+		hazardElement.Value = hazard
+	}
+
+	return nil
+}
+
+//exportPNG writes img to a PNG at csvDir named after the video at path, datasetName and date, returning the file name
+func exportPNG(path, csvDir, datasetName string, img image.Image, date time.Time) (string, error) {
+	fileName := generateExportItemFileName(path, csvDir, datasetName, date) + ".png"
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	err = png.Encode(file, img)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return fileName, nil
+}
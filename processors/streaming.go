@@ -0,0 +1,146 @@
+package processors
+
+import (
+	"github.com/lycerius/epilguard/hazards"
+	"github.com/lycerius/epilguard/profiles"
+)
+
+//flashStream finds flashes and flashing hazards as brightness accumulations arrive.
+//Only the region masks of frames that a hazard still in progress could span are kept
+type flashStream struct {
+	luminance     profiles.LuminanceRules
+	tracker       flashTracker
+	flashing      *flashingHazardDetector
+	extended      *extendedFlashingDetector
+	alerter       flashAlerter
+	last          BrightnessAccumulation //Accumulation of the last frame added
+	masks         []maskedFrame          //Ordered by frame index
+	width, height int
+
+	//Finished hazards, and their heatmaps in the same order
+	flashHazards, extendedHazards   []hazards.Hazard
+	flashHeatmaps, extendedHeatmaps []regionHeatmap
+}
+
+//flashUpdate is what the stream found in one frame
+type flashUpdate struct {
+	Flashes []Flash          //Trends that ended
+	Hazards []hazards.Hazard //Hazards that can't grow any more
+	Alerts  []Alert          //Flashing that just became hazardous
+}
+
+//newFlashStream creates a stream that finds the hazards profile describes in frames of width x height,
+//early alerts count the transition in progress
+func newFlashStream(profile profiles.Profile, fps, width, height int, early bool) *flashStream {
+	var stream flashStream
+	stream.luminance = profile.Luminance
+	stream.flashing = newFlashingHazardDetector(hazards.FlashHazard, profile.Frequency.FlashesPerSecondMax, fps)
+	stream.extended = newExtendedFlashingDetector(hazards.ExtendedFlashHazard, profile.Frequency.ExtendedSecondsMax, fps)
+	stream.alerter.early = early
+	stream.alerter.flashing = stream.flashing
+	stream.alerter.extended = stream.extended
+	stream.width = width
+	stream.height = height
+	return &stream
+}
+
+//add takes the accumulation of the next frame and returns the flash it ended, if any, the hazards it finished and the alerts it raised
+func (s *flashStream) add(accumulation BrightnessAccumulation) flashUpdate {
+	if !accumulation.mask.empty() {
+		s.masks = append(s.masks, maskedFrame{accumulation.Index, accumulation.mask})
+	}
+
+	var update flashUpdate
+	s.last = accumulation
+
+	if flash, ok := s.tracker.add(accumulation); ok {
+		update.Flashes = append(update.Flashes, flash)
+		update.Hazards = s.addFlash(flash)
+	}
+
+	//Flashes still to come peak no earlier than the trend in progress does so far
+	now := s.tracker.trend.PeakTime
+	update.Hazards = append(update.Hazards, s.localize(s.flashing.advance(now), &s.flashHazards, &s.flashHeatmaps)...)
+	update.Hazards = append(update.Hazards, s.localize(s.extended.advance(now), &s.extendedHazards, &s.extendedHeatmaps)...)
+
+	inProgress, ok := createFlashEvent(s.tracker.trend, s.luminance)
+	update.Alerts = s.alerter.check(accumulation, now, update.Hazards, inProgress, ok)
+
+	s.forgetMasks()
+	return update
+}
+
+//finish ends the trend in progress and returns it along with the hazards that were still in progress and their alerts
+func (s *flashStream) finish() flashUpdate {
+	var update flashUpdate
+
+	if flash, ok := s.tracker.finish(); ok {
+		update.Flashes = append(update.Flashes, flash)
+		update.Hazards = s.addFlash(flash)
+	}
+
+	update.Hazards = append(update.Hazards, s.localize(s.flashing.finish(), &s.flashHazards, &s.flashHeatmaps)...)
+	update.Hazards = append(update.Hazards, s.localize(s.extended.finish(), &s.extendedHazards, &s.extendedHeatmaps)...)
+	update.Alerts = s.alerter.check(s.last, s.last.Timestamp, update.Hazards, flashEvent{}, false)
+
+	s.masks = nil
+	return update
+}
+
+//addFlash hands flash to the detectors if it is strong enough to be half of a flash
+func (s *flashStream) addFlash(flash Flash) []hazards.Hazard {
+	event, ok := createFlashEvent(flash, s.luminance)
+	if !ok {
+		return nil
+	}
+
+	found := s.localize(s.flashing.add(event), &s.flashHazards, &s.flashHeatmaps)
+	return append(found, s.localize(s.extended.add(event), &s.extendedHazards, &s.extendedHeatmaps)...)
+}
+
+//localize finds the regions of the finished hazards and keeps them and their heatmaps
+func (s *flashStream) localize(finished []hazards.Hazard, kept *[]hazards.Hazard, heatmaps *[]regionHeatmap) []hazards.Hazard {
+	for i := range finished {
+		heatmap := localizeHazard(&finished[i], s.masks, s.width, s.height)
+		*kept = append(*kept, finished[i])
+		*heatmaps = append(*heatmaps, heatmap)
+	}
+	return finished
+}
+
+//forgetMasks drops the masks of frames before any hazard still to be finished could start
+func (s *flashStream) forgetMasks() {
+	horizon := s.tracker.trend.Peak
+	if frame, ok := s.flashing.horizon(); ok && frame < horizon {
+		horizon = frame
+	}
+	if frame, ok := s.extended.horizon(); ok && frame < horizon {
+		horizon = frame
+	}
+
+	forget := 0
+	for forget < len(s.masks) && s.masks[forget].Index < horizon {
+		forget++
+	}
+	s.masks = s.masks[forget:]
+}
+
+//hazardCount counts the hazards found so far, including those still in progress
+func (s *flashStream) hazardCount() int {
+	return len(s.flashHazards) + len(s.extendedHazards) + s.flashing.pending() + s.extended.pending()
+}
+
+//addTo adds every hazard found to report, flash hazards first, and returns their heatmaps in the same order
+func (s *flashStream) addTo(report *hazards.HazardReport) []regionHeatmap {
+	for _, hazard := range s.flashHazards {
+		report.Hazards.PushBack(hazard)
+	}
+	for _, hazard := range s.extendedHazards {
+		report.Hazards.PushBack(hazard)
+	}
+
+	heatmaps := make([]regionHeatmap, 0, len(s.flashHeatmaps)+len(s.extendedHeatmaps))
+	heatmaps = append(heatmaps, s.flashHeatmaps...)
+	heatmaps = append(heatmaps, s.extendedHeatmaps...)
+	return heatmaps
+}
@@ -0,0 +1,89 @@
+package processors
+
+import (
+	"math"
+
+	"github.com/lycerius/epilguard/profiles"
+)
+
+//viewport is the window the area threshold applies to, in frame pixels
+type viewport struct {
+	Width, Height int
+}
+
+//integralImage is a summed area table, the sum of any rectangle can be read in constant time
+type integralImage struct {
+	sums  []int
+	width int //Width of the table, one more than the width of the values
+}
+
+//newViewport scales the profile's reference viewport to a frame, profiles without a viewport use the whole frame
+func newViewport(area profiles.AreaRules, width, height int) viewport {
+	if area.ViewportWidth <= 0 || area.ViewportHeight <= 0 || area.ReferenceWidth <= 0 || area.ReferenceHeight <= 0 {
+		return viewport{width, height}
+	}
+
+	var window viewport
+	window.Width = int(math.Round(float64(area.ViewportWidth) * float64(width) / float64(area.ReferenceWidth)))
+	window.Height = int(math.Round(float64(area.ViewportHeight) * float64(height) / float64(area.ReferenceHeight)))
+	window.Width = clampDimension(window.Width, width)
+	window.Height = clampDimension(window.Height, height)
+	return window
+}
+
+//coversFrame checks if the viewport is the whole frame
+func (v viewport) coversFrame(width, height int) bool {
+	return v.Width >= width && v.Height >= height
+}
+
+//clampDimension keeps a window dimension between 1 and size
+func clampDimension(dimension, size int) int {
+	if dimension < 1 {
+		return 1
+	}
+	if dimension > size {
+		return size
+	}
+	return dimension
+}
+
+//newIntegralImage creates the summed area table of values, a width by height image
+func newIntegralImage(values []int, width, height int) integralImage {
+	var ii integralImage
+	ii.width = width + 1
+	ii.sums = make([]int, (width+1)*(height+1))
+
+	for y := 0; y < height; y++ {
+		rowSum := 0
+		for x := 0; x < width; x++ {
+			rowSum += values[y*width+x]
+			ii.sums[(y+1)*ii.width+x+1] = ii.sums[y*ii.width+x+1] + rowSum
+		}
+	}
+
+	return ii
+}
+
+//sum adds up the values in the w by h rectangle whose top left corner is x, y
+func (ii integralImage) sum(x, y, w, h int) int {
+	return ii.sums[(y+h)*ii.width+x+w] - ii.sums[y*ii.width+x+w] - ii.sums[(y+h)*ii.width+x] + ii.sums[y*ii.width+x]
+}
+
+//findBusiestWindow slides the viewport over a width by height image and returns the top left corner
+//of the window with the largest sum, along with that sum
+func findBusiestWindow(ii integralImage, window viewport, width, height int) (int, int, int) {
+	var bestX, bestY int
+	best := -1
+
+	for y := 0; y+window.Height <= height; y++ {
+		for x := 0; x+window.Width <= width; x++ {
+			sum := ii.sum(x, y, window.Width, window.Height)
+			if sum > best {
+				best = sum
+				bestX, bestY = x, y
+			}
+		}
+	}
+
+	return bestX, bestY, best
+}
@@ -0,0 +1,268 @@
+package processors
+
+import (
+	"time"
+
+	"github.com/lycerius/epilguard/hazards"
+)
+
+//How long flashing can pause before an extended flashing sequence ends
+const _ExtendedFlashGapMax = time.Second
+
+//flashEvent is a transition that is strong enough to be half of a flash, two opposing transitions make a flash
+type flashEvent struct {
+	Frame     uint          //Frame where the transition peaks
+	Time      time.Duration //Presentation time of Frame
+	Direction int           //1 for a transition up, -1 for a transition down
+}
+
+//flashingHazardDetector slides a one second window over events as they arrive and finds each stretch of video
+//where any one second period contains more than flashesMax flashes.
+//Only the events of one second windows that haven't been checked yet are kept
+type flashingHazardDetector struct {
+	hazardType             string
+	flashesMax, fps        int
+	events                 []flashEvent //Events from the start of the first unchecked window
+	hazardStart, hazardEnd flashEvent
+	hazardFlashes          int //Most flashes in any window of the hazard in progress
+	inHazard               bool
+}
+
+//newFlashingHazardDetector creates a detector for more than flashesMax flashes in any one second period
+func newFlashingHazardDetector(hazardType string, flashesMax, fps int) *flashingHazardDetector {
+	var detector flashingHazardDetector
+	detector.hazardType = hazardType
+	detector.flashesMax = flashesMax
+	detector.fps = fps
+	return &detector
+}
+
+//add takes the next event, events must arrive in time order. Hazards that can't grow any more are returned
+func (d *flashingHazardDetector) add(event flashEvent) []hazards.Hazard {
+	d.events = append(d.events, event)
+	return d.check(event.Time, false)
+}
+
+//advance checks the windows that are complete now that no event can arrive before now
+func (d *flashingHazardDetector) advance(now time.Duration) []hazards.Hazard {
+	return d.check(now, false)
+}
+
+//finish checks the remaining windows, there are no more events
+func (d *flashingHazardDetector) finish() []hazards.Hazard {
+	return d.check(0, true)
+}
+
+//check slides the window along while the window is complete, or over everything that is left when final
+func (d *flashingHazardDetector) check(now time.Duration, final bool) []hazards.Hazard {
+	finished := make([]hazards.Hazard, 0)
+
+	for len(d.events) > 0 {
+		//Grow the window to one second after its first event
+		windowEnd := 0
+		for windowEnd < len(d.events) && d.events[windowEnd].Time-d.events[0].Time < time.Second {
+			windowEnd++
+		}
+
+		//Events still to come may be part of the window
+		if !final && windowEnd == len(d.events) && now-d.events[0].Time < time.Second {
+			break
+		}
+
+		window := d.events[:windowEnd]
+		d.events = d.events[1:]
+
+		//No later window can overlap the hazard, so it is finished
+		if d.inHazard && window[0].Frame > d.hazardEnd.Frame {
+			finished = append(finished, newFlashEventHazard(d.hazardType, d.hazardStart, d.hazardEnd, d.fps))
+			d.inHazard = false
+		}
+
+		flashes := countFlashes(window)
+		if flashes <= d.flashesMax {
+			continue
+		}
+
+		//Overlapping windows are the same hazard
+		if !d.inHazard {
+			d.hazardStart = window[0]
+			d.hazardFlashes = 0
+			d.inHazard = true
+		}
+		d.hazardEnd = window[len(window)-1]
+		if flashes > d.hazardFlashes {
+			d.hazardFlashes = flashes
+		}
+	}
+
+	//Windows still to be checked start after the hazard ends, so none of them can overlap it
+	if d.inHazard && (final || len(d.events) == 0 || d.events[0].Frame > d.hazardEnd.Frame) {
+		finished = append(finished, newFlashEventHazard(d.hazardType, d.hazardStart, d.hazardEnd, d.fps))
+		d.inHazard = false
+	}
+
+	return finished
+}
+
+//horizon is the first frame a hazard still to be returned could start on, false when it could start on any later event
+func (d *flashingHazardDetector) horizon() (uint, bool) {
+	if d.inHazard {
+		return d.hazardStart.Frame, true
+	}
+	if len(d.events) > 0 {
+		return d.events[0].Frame, true
+	}
+	return 0, false
+}
+
+//latest returns the last event and the events less than a second before it
+func (d *flashingHazardDetector) latest() []flashEvent {
+	if len(d.events) == 0 {
+		return nil
+	}
+	return eventsBefore(d.events, d.events[len(d.events)-1].Time)
+}
+
+//pending counts the hazards found that haven't been returned yet
+func (d *flashingHazardDetector) pending() int {
+	if d.inHazard {
+		return 1
+	}
+	return 0
+}
+
+//extendedFlashingDetector finds every sequence of flashes that lasts longer than durationMax as events arrive,
+//flashing continues as long as every flash starts within a second of the previous one ending
+type extendedFlashingDetector struct {
+	hazardType                 string
+	durationMax                time.Duration //0 does not limit how long flashing may continue
+	fps                        int
+	sequenceStart, sequenceEnd flashEvent
+	inSequence                 bool
+	unpaired                   flashEvent //Transition waiting for its opposite to make a flash
+	hasUnpaired                bool
+}
+
+//newExtendedFlashingDetector creates a detector for flashing that lasts longer than secondsMax
+func newExtendedFlashingDetector(hazardType string, secondsMax float32, fps int) *extendedFlashingDetector {
+	var detector extendedFlashingDetector
+	detector.hazardType = hazardType
+	detector.fps = fps
+	if secondsMax > 0 {
+		detector.durationMax = time.Duration(float64(secondsMax) * float64(time.Second))
+	}
+	return &detector
+}
+
+//add takes the next event, events must arrive in time order. A sequence the event ends is returned if it lasted too long
+func (d *extendedFlashingDetector) add(event flashEvent) []hazards.Hazard {
+	if d.durationMax == 0 {
+		return nil
+	}
+
+	//Wait for the opposing transition that completes the flash
+	if !d.hasUnpaired || event.Direction == d.unpaired.Direction {
+		d.unpaired = event
+		d.hasUnpaired = true
+		return nil
+	}
+
+	flashStart := d.unpaired
+	d.hasUnpaired = false
+
+	var finished []hazards.Hazard
+	if d.inSequence && flashStart.Time-d.sequenceEnd.Time > _ExtendedFlashGapMax {
+		finished = d.closeSequence()
+	}
+
+	if !d.inSequence {
+		d.sequenceStart = flashStart
+		d.inSequence = true
+	}
+	d.sequenceEnd = event
+
+	return finished
+}
+
+//advance ends the sequence once no event arriving from now on could continue it
+func (d *extendedFlashingDetector) advance(now time.Duration) []hazards.Hazard {
+	if !d.inSequence {
+		return nil
+	}
+
+	//A waiting transition may still become a flash that continues the sequence
+	nextFlash := now
+	if d.hasUnpaired {
+		nextFlash = d.unpaired.Time
+	}
+
+	if nextFlash-d.sequenceEnd.Time > _ExtendedFlashGapMax {
+		return d.closeSequence()
+	}
+	return nil
+}
+
+//finish ends the sequence in progress, there are no more events
+func (d *extendedFlashingDetector) finish() []hazards.Hazard {
+	return d.closeSequence()
+}
+
+//closeSequence ends the sequence in progress and returns it if it lasted too long
+func (d *extendedFlashingDetector) closeSequence() []hazards.Hazard {
+	var finished []hazards.Hazard
+	if d.inSequence && d.sequenceEnd.Time-d.sequenceStart.Time > d.durationMax {
+		finished = append(finished, newFlashEventHazard(d.hazardType, d.sequenceStart, d.sequenceEnd, d.fps))
+	}
+	d.inSequence = false
+	return finished
+}
+
+//horizon is the first frame a hazard still to be returned could start on, false when it could start on any later event
+func (d *extendedFlashingDetector) horizon() (uint, bool) {
+	if d.inSequence {
+		return d.sequenceStart.Frame, true
+	}
+	if d.hasUnpaired {
+		return d.unpaired.Frame, true
+	}
+	return 0, false
+}
+
+//pending counts the hazards found that haven't been returned yet
+func (d *extendedFlashingDetector) pending() int {
+	if d.inSequence && d.durationMax > 0 && d.sequenceEnd.Time-d.sequenceStart.Time > d.durationMax {
+		return 1
+	}
+	return 0
+}
+
+//eventsBefore returns the events less than a second before end, events must be in time order and none after end
+func eventsBefore(events []flashEvent, end time.Duration) []flashEvent {
+	first := len(events)
+	for first > 0 && end-events[first-1].Time < time.Second {
+		first--
+	}
+	return events[first:]
+}
+
+//countFlashes counts the pairs of opposing transitions in events
+func countFlashes(events []flashEvent) int {
+	flashes := 0
+	unpaired := 0
+
+	for _, event := range events {
+		if unpaired != 0 && event.Direction != unpaired {
+			flashes++
+			unpaired = 0
+		} else {
+			unpaired = event.Direction
+		}
+	}
+
+	return flashes
+}
+
+//newFlashEventHazard creates a hazard spanning the events start to end
+func newFlashEventHazard(hazardType string, start, end flashEvent, fps int) hazards.Hazard {
+	return hazards.NewHazard(hazardType, start.Frame, end.Frame, start.Time, end.Time+frameTime(1, fps), fps)
+}
@@ -0,0 +1,179 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lycerius/epilguard/equations"
+)
+
+//Profile bundles the rules a compliance standard uses to decide what is hazardous
+type Profile struct {
+	Name      string         `json:"name"`
+	Luminance LuminanceRules `json:"luminance"`
+	Area      AreaRules      `json:"area"`
+	Frequency FrequencyRules `json:"frequency"`
+	Red       RedFlashRules  `json:"red"`
+	Pattern   PatternRules   `json:"pattern"`
+}
+
+//LuminanceRules decide when a change in brightness is half of a flash
+type LuminanceRules struct {
+	FlashDelta        float32 `json:"flashDelta"`        //Smallest change in cd/m^2 that counts
+	DarkBrightnessMax float32 `json:"darkBrightnessMax"` //The darker image must be below this many cd/m^2
+}
+
+//AreaRules decide how much of the screen has to take part in a flash.
+//When a viewport is set the fraction applies to any viewport sized window instead of the whole screen,
+//the viewport is given at a reference resolution and scaled to the frame
+type AreaRules struct {
+	Fraction        float32 `json:"fraction"`        //Fraction of the screen or viewport
+	ViewportWidth   int     `json:"viewportWidth"`   //Width of the viewport at the reference resolution, 0 uses the whole screen
+	ViewportHeight  int     `json:"viewportHeight"`  //Height of the viewport at the reference resolution, 0 uses the whole screen
+	ReferenceWidth  int     `json:"referenceWidth"`  //Width of the screen the viewport was measured on
+	ReferenceHeight int     `json:"referenceHeight"` //Height of the screen the viewport was measured on
+}
+
+//FrequencyRules decide how much flashing is allowed over time
+type FrequencyRules struct {
+	FlashesPerSecondMax int     `json:"flashesPerSecondMax"` //Most flashes allowed in any one second period
+	ExtendedSecondsMax  float32 `json:"extendedSecondsMax"`  //Longest flashing may continue, 0 does not limit it
+}
+
+//RedFlashRules decide when a change in color is a saturated red transition
+type RedFlashRules struct {
+	SaturatedRatio    float32 `json:"saturatedRatio"`    //Smallest R/(R+G+B) that is saturated red
+	ChromaticityDelta float32 `json:"chromaticityDelta"` //Change in CIE 1976 UCS chromaticity that must be exceeded
+}
+
+//PatternRules decide when a regular pattern is hazardous
+type PatternRules struct {
+	Enabled  bool `json:"enabled"`  //Whether the standard covers patterns
+	PairsMax int  `json:"pairsMax"` //Most light-dark pairs a pattern may have
+}
+
+//BT1702 ITU-R BT.1702, the default profile
+var BT1702 = Profile{
+	Name:      "BT.1702",
+	Luminance: LuminanceRules{equations.FlashDeltaMax, equations.DarkBrightnessMax},
+	Area:      AreaRules{Fraction: equations.PercentageFlashArea},
+	Frequency: FrequencyRules{equations.FlashFrequencyMax, 0},
+	Red:       RedFlashRules{equations.SaturatedRedRatio, equations.RedChromaticityDeltaMin},
+	Pattern:   PatternRules{true, equations.PatternPairsMax},
+}
+
+//Ofcom UK Ofcom Guidance Note on flashing images and regular patterns in television.
+//It uses the ITU-R thresholds and also stops flashing from continuing for more than 5 seconds
+var Ofcom = Profile{
+	Name:      "Ofcom",
+	Luminance: BT1702.Luminance,
+	Area:      BT1702.Area,
+	Frequency: FrequencyRules{equations.FlashFrequencyMax, equations.ExtendedFlashSecondsMax},
+	Red:       BT1702.Red,
+	Pattern:   BT1702.Pattern,
+}
+
+//WCAG231 W3C WCAG 2.x success criterion 2.3.1, general flash and red flash thresholds.
+//10% of the maximum relative luminance is 20cd/m^2 and 0.8 is 160cd/m^2 on the 200cd/m^2 reference display.
+//The area is 25% of any 10 degree visual field, a 341x256 rectangle of a 1024x768 screen.
+//WCAG does not cover regular patterns or extended flashing
+var WCAG231 = Profile{
+	Name:      "WCAG-2.3.1",
+	Luminance: BT1702.Luminance,
+	Area:      AreaRules{equations.PercentageFlashArea, 341, 256, 1024, 768},
+	Frequency: FrequencyRules{equations.FlashFrequencyMax, 0},
+	Red:       BT1702.Red,
+	Pattern:   PatternRules{false, equations.PatternPairsMax},
+}
+
+//BuiltIn every profile that comes with epilguard
+var BuiltIn = []Profile{BT1702, Ofcom, WCAG231}
+
+//Default returns the profile used when none is chosen
+func Default() Profile {
+	return BT1702
+}
+
+//Lookup finds a built in profile by name, ignoring case
+func Lookup(name string) (Profile, bool) {
+	for _, profile := range BuiltIn {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+//Find returns the built in profile called nameOrPath, or loads a custom profile from the file at nameOrPath
+func Find(nameOrPath string) (Profile, error) {
+	if profile, ok := Lookup(nameOrPath); ok {
+		return profile, nil
+	}
+
+	if _, err := os.Stat(nameOrPath); err != nil {
+		return Profile{}, errors.New("No built in profile or profile file named " + nameOrPath)
+	}
+
+	return Load(nameOrPath)
+}
+
+//Load reads a custom profile from a JSON file, rules missing from the file keep their BT.1702 values
+func Load(path string) (Profile, error) {
+	profile := Default()
+	profile.Name = ""
+
+	file, err := os.Open(path)
+	if err != nil {
+		return profile, err
+	}
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&profile)
+	if err != nil {
+		return profile, err
+	}
+
+	if profile.Name == "" {
+		profile.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return profile, profile.Validate()
+}
+
+//Validate checks that the rules can be used for analysis
+func (p *Profile) Validate() error {
+	if p.Area.Fraction <= 0 || p.Area.Fraction > 1 {
+		return errors.New("Profile area fraction must be between 0 and 1")
+	}
+	if p.Area.ViewportWidth < 0 || p.Area.ViewportHeight < 0 {
+		return errors.New("Profile viewport must not be negative")
+	}
+	if (p.Area.ViewportWidth > 0) != (p.Area.ViewportHeight > 0) {
+		return errors.New("Profile viewport needs both a width and a height")
+	}
+	if p.Area.ViewportWidth > 0 && (p.Area.ReferenceWidth <= 0 || p.Area.ReferenceHeight <= 0) {
+		return errors.New("Profile viewport needs a reference resolution")
+	}
+	if p.Luminance.FlashDelta < 0 {
+		return errors.New("Profile luminance flash delta must not be negative")
+	}
+	if p.Luminance.DarkBrightnessMax < 0 {
+		return errors.New("Profile dark brightness must not be negative")
+	}
+	if p.Red.SaturatedRatio <= 0 || p.Red.SaturatedRatio > 1 {
+		return errors.New("Profile red saturated ratio must be between 0 and 1")
+	}
+	if p.Red.ChromaticityDelta < 0 {
+		return errors.New("Profile red chromaticity delta must not be negative")
+	}
+	if p.Frequency.FlashesPerSecondMax < 0 {
+		return errors.New("Profile flashes per second must not be negative")
+	}
+	if p.Frequency.ExtendedSecondsMax < 0 {
+		return errors.New("Profile extended flashing seconds must not be negative")
+	}
+	return nil
+}
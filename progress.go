@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lycerius/epilguard/processors"
+)
+
+//Ways of showing progress
+const (
+	progressBar  = "bar"
+	progressJSON = "json"
+	progressNone = "none"
+)
+
+//How many characters wide the progress bar is
+const _ProgressBarWidth = 30
+
+//progressLine is one line of -progress=json output
+type progressLine struct {
+	Pass        string  `json:"pass"`        //Which analysis the progress is for, flash, which also finds red flashes and patterns
+	Frames      int     `json:"frames"`      //Frames analyzed so far
+	TotalFrames int     `json:"totalFrames"` //Frames in the video, 0 when unknown
+	Percent     float32 `json:"percent"`     //0 to 100, 0 when the length of the video is unknown
+	Elapsed     float64 `json:"elapsed"`     //Seconds spent analyzing so far
+	ETA         float64 `json:"eta"`         //Estimated seconds left, 0 when the length of the video is unknown
+	Hazards     int     `json:"hazards"`     //Hazards found so far
+	Done        bool    `json:"done"`        //Whether the pass has analyzed every frame
+}
+
+//createProgressFunc shows the progress of pass in format, bars are drawn on bars and JSON lines are written to lines
+func createProgressFunc(format, pass string, bars, lines io.Writer) processors.ProgressFunc {
+	switch format {
+	case progressJSON:
+		encoder := json.NewEncoder(lines)
+		return func(progress processors.Progress) {
+			encoder.Encode(progressLine{
+				Pass:        pass,
+				Frames:      progress.Frames,
+				TotalFrames: progress.TotalFrames,
+				Percent:     progress.Percent,
+				Elapsed:     progress.Elapsed.Seconds(),
+				ETA:         progress.ETA.Seconds(),
+				Hazards:     progress.Hazards,
+				Done:        progress.Done,
+			})
+		}
+	case progressBar:
+		return func(progress processors.Progress) {
+			fmt.Fprint(bars, "\r"+formatProgressBar(pass, progress))
+			if progress.Done {
+				fmt.Fprintln(bars)
+			}
+		}
+	}
+	return nil
+}
+
+//formatProgressBar draws progress as a single line, videos of unknown length only get a frame count
+func formatProgressBar(pass string, progress processors.Progress) string {
+	elapsed := progress.Elapsed.Round(time.Second)
+
+	if progress.TotalFrames == 0 && progress.Duration == 0 {
+		return fmt.Sprintf("%-8s %d frames  elapsed %v  hazards %d", pass, progress.Frames, elapsed, progress.Hazards)
+	}
+
+	filled := int(progress.Percent / 100 * _ProgressBarWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", _ProgressBarWidth-filled)
+	return fmt.Sprintf("%-8s [%s] %5.1f%%  elapsed %v  ETA %v  hazards %d ",
+		pass, bar, progress.Percent, elapsed, progress.ETA.Round(time.Second), progress.Hazards)
+}
+
+//validProgressFormat checks that format is a way of showing progress
+func validProgressFormat(format string) bool {
+	return format == progressBar || format == progressJSON || format == progressNone
+}
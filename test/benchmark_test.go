@@ -0,0 +1,52 @@
+package test
+
+import (
+	"math/rand"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/lycerius/epilguard/decoder"
+	"github.com/lycerius/epilguard/processors"
+	"github.com/stretchr/testify/assert"
+)
+
+//createNoiseFrames creates frames of random pixels, so every pixel changes between frames
+func createNoiseFrames(width, height, totalFrames int) []decoder.Frame {
+	random := rand.New(rand.NewSource(1702))
+	frames := make([]decoder.Frame, totalFrames)
+
+	for i := range frames {
+		pixels := make([]byte, width*height*3)
+		random.Read(pixels)
+		frames[i] = decoder.NewFrame(width, height, pixels)
+	}
+	return frames
+}
+
+func BenchmarkFlashingProcessorWorkers(b *testing.B) {
+	assert := assert.New(b)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	frames := createNoiseFrames(640, 360, 30)
+
+	workerCounts := []int{1, 2, 4}
+	if runtime.NumCPU() > 4 {
+		workerCounts = append(workerCounts, runtime.NumCPU())
+	}
+
+	for _, workers := range workerCounts {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				source := decoder.NewMemorySource("benchmark.mp4", frames, 30)
+				proc := processors.NewFlashingProcessor(&source, Test_Report_Directory)
+				proc.Workers = workers
+				err := proc.Process()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,15 @@
 package test
 
 import (
+	"context"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/lycerius/epilguard/decoder"
 	"github.com/stretchr/testify/assert"
@@ -53,3 +61,193 @@ func TestDecoderCanDecodeFullVideo(t *testing.T) {
 
 	assert.Equal(err.Error(), "EOF", "Unexpected error occured", err)
 }
+
+func TestStreamDecoderDecodesWithoutProbing(t *testing.T) {
+	assert := assert.New(t)
+
+	source := decoder.NewStreamDecoder(Test_Video_White)
+	err := source.Start()
+	if !assert.NoError(err) {
+		return
+	}
+	defer source.Close()
+
+	assert.Equal(24, source.FramesPerSecond)
+	assert.Equal(480, source.FrameHeight)
+
+	frames := 0
+	for _, err = source.NextFrame(); err == nil; _, err = source.NextFrame() {
+		frames++
+	}
+	assert.ErrorIs(err, io.EOF)
+	assert.True(frames > 0)
+
+	//Streams aren't probed, so their length is unknown
+	totalFrames, duration := source.Length()
+	assert.Equal(0, totalFrames)
+	assert.Equal(time.Duration(0), duration)
+}
+
+func TestDecoderReadsFromReader(t *testing.T) {
+	assert := assert.New(t)
+
+	file, err := os.Open(Test_Video_Porygon)
+	if !assert.NoError(err) {
+		return
+	}
+	defer file.Close()
+
+	source := decoder.NewDecoderFromReader("porygon", file)
+	err = source.Start()
+	if !assert.NoError(err) {
+		return
+	}
+	defer source.Close()
+
+	assert.Equal("porygon", source.Name())
+	assert.True(source.FramesPerSecond > 0)
+
+	frames := 0
+	for _, err = source.NextFrame(); err == nil; _, err = source.NextFrame() {
+		frames++
+	}
+	assert.ErrorIs(err, io.EOF)
+	assert.True(frames > 0)
+}
+
+func TestDecoderStopsWhenContextIsCancelled(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	decoder := decoder.NewDecoder(Test_Video_White)
+	err := decoder.StartContext(ctx)
+	if !assert.NoError(err) {
+		cancel()
+		return
+	}
+
+	_, err = decoder.NextFrameContext(ctx)
+	assert.NoError(err)
+
+	cancel()
+
+	//Frames already buffered can still come out, but the decoder stops soon after
+	for _, err = decoder.NextFrameContext(ctx); err == nil; _, err = decoder.NextFrameContext(ctx) {
+	}
+	assert.ErrorIs(err, context.Canceled)
+
+	decoder.Close()
+}
+
+func TestDecoderCloseWaitsForFFmpeg(t *testing.T) {
+	assert := assert.New(t)
+	decoder := createDecoderTestDecoder(Test_Video_White, assert)
+
+	_, err := decoder.NextFrame()
+	assert.NoError(err)
+
+	//Close kills ffmpeg and only returns once it has exited, after which the decoder is empty
+	decoder.Close()
+	assert.False(decoder.IsOpen())
+}
+
+func TestDecoderRejectsCorruptFile(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	file := filepath.Join(Test_Report_Directory, "corrupt.mp4")
+	assert.NoError(ioutil.WriteFile(file, []byte("this is not a video"), 0644))
+
+	source := decoder.NewDecoder(file)
+	err := source.Start()
+	assert.ErrorIs(err, decoder.ErrDecodeFailed)
+}
+
+func TestDecoderNeedsStarting(t *testing.T) {
+	assert := assert.New(t)
+
+	//Reading before starting is an error for every source that needs starting, not an empty video
+	ffmpeg := decoder.NewDecoder(Test_Video_White)
+	y4m := decoder.NewY4MSourceFromReader("test.y4m", createY4MStream("YUV4MPEG2 W4 H2 F30:1 C420jpeg", 0, 255))
+	gif := decoder.NewGIFSourceFromReader("test.gif", createGIFStream(assert, -1, []int{10, 10}, nil, color.Black, color.White))
+	sources := []decoder.FrameSource{&ffmpeg, &y4m, &gif}
+
+	for _, source := range sources {
+		_, err := source.NextFrame()
+		assert.ErrorIs(err, decoder.ErrNotStarted)
+	}
+}
+
+func TestDecoderKnowsVideoLength(t *testing.T) {
+	assert := assert.New(t)
+	decoder := createDecoderTestDecoder(Test_Video_White, assert)
+	defer decoder.Close()
+
+	frames, duration := decoder.Length()
+	assert.True(frames > 0)
+	assert.True(duration > 0)
+}
+
+//showinfoLine formats a line the way ffmpeg's showinfo filter logs frame n
+func showinfoLine(n int, ptsTime string) string {
+	return fmt.Sprintf("[Parsed_showinfo_1 @ 0x55d0c8c0] n:%4d pts:%7d pts_time:%-8s duration:      1 fmt:rgb24 sar:1/1 s:854x480 i:P iskey:1 type:I\n", n, n*1000, ptsTime)
+}
+
+func TestFrameClockTimesFramesFromShowinfo(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  []time.Duration
+	}{
+		{
+			"uneven timestamps",
+			[]string{showinfoLine(0, "0"), showinfoLine(1, "0.04"), showinfoLine(2, "0.1"), showinfoLine(3, "0.25")},
+			[]time.Duration{0, 40 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond},
+		},
+		{
+			"relative to the first frame",
+			[]string{showinfoLine(0, "1.5"), showinfoLine(1, "1.54"), showinfoLine(2, "1.7")},
+			[]time.Duration{0, 40 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			"other lines are ignored",
+			[]string{"frame=    2 fps=0.0 q=-0.0 size=N/A\n", showinfoLine(0, "0"), "Stream #0:0: Video: rawvideo\n", showinfoLine(1, "0.3")},
+			[]time.Duration{0, 300 * time.Millisecond},
+		},
+		{
+			"missing line",
+			[]string{showinfoLine(0, "0"), showinfoLine(1, "0.04"), showinfoLine(3, "0.5"), showinfoLine(4, "0.6")},
+			[]time.Duration{0, 40 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond, 600 * time.Millisecond},
+		},
+		{
+			"malformed timestamp",
+			[]string{showinfoLine(0, "0"), showinfoLine(1, "NOPTS"), showinfoLine(2, "nan"), showinfoLine(3, "0.5")},
+			[]time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			"malformed first timestamp",
+			[]string{showinfoLine(0, "NOPTS"), showinfoLine(1, "0.3")},
+			[]time.Duration{0, 300 * time.Millisecond},
+		},
+		{
+			"log ends early",
+			[]string{showinfoLine(0, "2"), showinfoLine(1, "2.05")},
+			[]time.Duration{0, 50 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+		},
+	}
+
+	for _, test := range tests {
+		clock := decoder.NewFrameClock(10, len(test.lines))
+		for _, line := range test.lines {
+			clock.Log(context.Background(), line)
+		}
+		clock.Close()
+
+		for i, want := range test.want {
+			assert.Equalf(want, clock.Time(uint(i)), "%s: frame %d", test.name, i)
+		}
+	}
+}
@@ -1,14 +1,26 @@
 package test
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/lycerius/epilguard/decoder"
 	"github.com/lycerius/epilguard/hazards"
 
 	"github.com/lycerius/epilguard/processors"
+	"github.com/lycerius/epilguard/profiles"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,6 +31,131 @@ func createTestProcessor(path string, t *assert.Assertions) processors.FlashingP
 	return proc
 }
 
+func createMemoryTestProcessor(frames []decoder.Frame, fps int, t *assert.Assertions) processors.FlashingProcessor {
+	source := decoder.NewMemorySource("memory.mp4", frames, fps)
+	proc := processors.NewFlashingProcessor(&source, Test_Report_Directory)
+	createTestDirectory(t)
+	return proc
+}
+
+//createSolidFrame creates a frame where every pixel has the same gray value
+func createSolidFrame(width, height int, value byte) decoder.Frame {
+	pixels := make([]byte, width*height*3)
+	for i := range pixels {
+		pixels[i] = value
+	}
+	return decoder.NewFrame(width, height, pixels)
+}
+
+//createFlashingFrames creates black frames with white flashes starting at frame offset,
+//every flash is white for onFrames and then black for offFrames
+func createFlashingFrames(totalFrames, offset, flashes, onFrames, offFrames int) []decoder.Frame {
+	return createGrayFlashingFrames(totalFrames, offset, flashes, onFrames, offFrames, 0, 255)
+}
+
+//createGrayFlashingFrames is createFlashingFrames with flashes from the gray value off to the gray value on
+func createGrayFlashingFrames(totalFrames, offset, flashes, onFrames, offFrames int, off, on byte) []decoder.Frame {
+	offFrame := createSolidFrame(32, 24, off)
+	onFrame := createSolidFrame(32, 24, on)
+	frames := make([]decoder.Frame, totalFrames)
+
+	for i := range frames {
+		frames[i] = offFrame
+		position := i - offset
+		if position >= 0 && position < flashes*(onFrames+offFrames) && position%(onFrames+offFrames) < onFrames {
+			frames[i] = onFrame
+		}
+	}
+	return frames
+}
+
+//createColorFlashingFrames creates frames of the color off with flashes to the color on,
+//every flash is on for onFrames and then off for offFrames
+func createColorFlashingFrames(totalFrames, flashes, onFrames, offFrames int, off, on color.RGBA) []decoder.Frame {
+	offFrame := createColorFrame(32, 24, off)
+	onFrame := createColorFrame(32, 24, on)
+	frames := make([]decoder.Frame, totalFrames)
+
+	for i := range frames {
+		frames[i] = offFrame
+		if i < flashes*(onFrames+offFrames) && i%(onFrames+offFrames) < onFrames {
+			frames[i] = onFrame
+		}
+	}
+	return frames
+}
+
+//createColorFrame creates a frame where every pixel is the color c
+func createColorFrame(width, height int, c color.RGBA) decoder.Frame {
+	pixels := make([]byte, 0, width*height*3)
+	for i := 0; i < width*height; i++ {
+		pixels = append(pixels, c.R, c.G, c.B)
+	}
+	return decoder.NewFrame(width, height, pixels)
+}
+
+//createPatternFrame creates a black 96x96 frame with pairs white stripes of width cell, starting a stripe in from the left edge,
+//that are height pixels tall. A checkerboard has pairs white cells in every row and column instead
+func createPatternFrame(cell, pairs, height int, checkerboard bool) decoder.Frame {
+	const size = 96
+	pixels := make([]byte, size*size*3)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < 2*pairs*cell && x < size; x++ {
+			white := (x/cell)%2 == 1
+			if checkerboard {
+				white = (x/cell+y/cell)%2 == 1 && y < 2*pairs*cell
+			}
+			if white {
+				position := (y*size + x) * 3
+				pixels[position], pixels[position+1], pixels[position+2] = 255, 255, 255
+			}
+		}
+	}
+	return decoder.NewFrame(size, size, pixels)
+}
+
+//countHazards counts the hazards of hazardType in report
+func countHazards(report hazards.HazardReport, hazardType string) int {
+	count := 0
+	for hazardElement := report.Hazards.Front(); hazardElement != nil; hazardElement = hazardElement.Next() {
+		if hazardElement.Value.(hazards.Hazard).HazardType == hazardType {
+			count++
+		}
+	}
+	return count
+}
+
+//createCornerFlashingSource creates a full range 4:4:4 YUV4MPEG2 source of black frames where only a
+//regionWidth by regionHeight corner flashes, every flash is the color on for onFrames and then black for offFrames
+func createCornerFlashingSource(width, height, regionWidth, regionHeight, totalFrames, flashes, onFrames, offFrames int, on color.RGBA, t *assert.Assertions) *decoder.Y4MSource {
+	onY, onCb, onCr := color.RGBToYCbCr(on.R, on.G, on.B)
+	offPlanes := append(make([]byte, width*height), bytes.Repeat([]byte{128}, width*height*2)...)
+	onPlanes := make([]byte, len(offPlanes))
+	copy(onPlanes, offPlanes)
+	for y := 0; y < regionHeight; y++ {
+		for x := 0; x < regionWidth; x++ {
+			position := y*width + x
+			onPlanes[position], onPlanes[width*height+position], onPlanes[2*width*height+position] = onY, onCb, onCr
+		}
+	}
+
+	var stream bytes.Buffer
+	fmt.Fprintf(&stream, "YUV4MPEG2 W%d H%d F30:1 Ip C444 XCOLORRANGE=FULL\n", width, height)
+	for i := 0; i < totalFrames; i++ {
+		stream.WriteString("FRAME\n")
+		if i < flashes*(onFrames+offFrames) && i%(onFrames+offFrames) < onFrames {
+			stream.Write(onPlanes)
+		} else {
+			stream.Write(offPlanes)
+		}
+	}
+
+	source := decoder.NewY4MSourceFromReader("memory.y4m", &stream)
+	t.NoError(source.Start())
+	return &source
+}
+
 func createTestDirectory(t *assert.Assertions) {
 	err := os.Mkdir(Test_Report_Directory, 0777)
 	if err != nil {
@@ -76,3 +213,652 @@ func TestProcessorFailsOhhGod(t *testing.T) {
 	assert.Equal(0, int(hazard.Start), "Expected hazard start to be 0")
 	assert.Equal(5, int(hazard.End), "Expected hazard to end at 5")
 }
+
+func TestProcessorAcceptsMemorySource(t *testing.T) {
+	assert := assert.New(t)
+	frames := make([]decoder.Frame, 0)
+	for i := 0; i < 60; i++ {
+		frames = append(frames, createSolidFrame(32, 24, 0))
+	}
+	proc := createMemoryTestProcessor(frames, 30, assert)
+	defer emptyTestDirectory(assert)
+
+	err := proc.Process()
+	assert.NoError(err)
+
+	report := proc.HazardReport
+	assert.Equalf(0, report.Hazards.Len(), "Expected no hazards, got %d", report.Hazards.Len())
+}
+
+func TestProcessorDetectsFlashesStraddlingSecondBoundary(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	//4 flashes over 0.8 seconds, starting at different offsets across the 1 second mark
+	for _, offset := range []int{20, 25, 28, 29, 30} {
+		proc := createMemoryTestProcessor(createFlashingFrames(90, offset, 4, 3, 3), 30, assert)
+		err := proc.Process()
+		assert.NoError(err)
+
+		report := proc.HazardReport
+		if !assert.Equalf(1, report.Hazards.Len(), "Expected 1 hazard at offset %d, got %d", offset, report.Hazards.Len()) {
+			continue
+		}
+
+		hazard := report.Hazards.Front().Value.(hazards.Hazard)
+		assert.Equal(uint(offset), hazard.StartFrame, "Hazard should start at the first flash")
+		assert.Equal(uint(offset+21), hazard.EndFrame, "Hazard should end at the last flash")
+	}
+}
+
+func TestProcessorAllowsThreeFlashesPerSecond(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	for _, offset := range []int{10, 25} {
+		proc := createMemoryTestProcessor(createFlashingFrames(90, offset, 3, 3, 3), 30, assert)
+		err := proc.Process()
+		assert.NoError(err)
+
+		report := proc.HazardReport
+		assert.Equalf(0, report.Hazards.Len(), "Expected no hazards at offset %d, got %d", offset, report.Hazards.Len())
+	}
+}
+
+func TestProcessorAllowsSlowFlashing(t *testing.T) {
+	assert := assert.New(t)
+	proc := createMemoryTestProcessor(createFlashingFrames(150, 5, 8, 8, 7), 30, assert)
+	defer emptyTestDirectory(assert)
+
+	err := proc.Process()
+	assert.NoError(err)
+
+	report := proc.HazardReport
+	assert.Equalf(0, report.Hazards.Len(), "Expected no hazards, got %d", report.Hazards.Len())
+}
+
+func TestProcessorAllowsFlashesBetweenBrightImages(t *testing.T) {
+	assert := assert.New(t)
+	proc := createMemoryTestProcessor(createGrayFlashingFrames(90, 10, 10, 2, 2, 235, 255), 30, assert)
+	defer emptyTestDirectory(assert)
+
+	err := proc.Process()
+	assert.NoError(err)
+
+	//The darker image is brighter than 160cd/m^2
+	report := proc.HazardReport
+	assert.Equalf(0, report.Hazards.Len(), "Expected no hazards, got %d", report.Hazards.Len())
+}
+
+func TestProcessorDetectsExtendedFlashing(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	//3 flashes per second is allowed in any one second, but Ofcom doesn't allow it for 7 seconds straight
+	proc := createMemoryTestProcessor(createFlashingFrames(240, 15, 21, 5, 5), 30, assert)
+	proc.Profile = profiles.Ofcom
+	err := proc.Process()
+	assert.NoError(err)
+
+	report := proc.HazardReport
+	if assert.Equalf(1, report.Hazards.Len(), "Expected 1 hazard, got %d", report.Hazards.Len()) {
+		hazard := report.Hazards.Front().Value.(hazards.Hazard)
+		assert.Equal(hazards.ExtendedFlashHazard, hazard.HazardType)
+		assert.Equal(uint(15), hazard.StartFrame)
+		assert.True(hazard.Duration > 5, "Extended flashing should last longer than 5 seconds")
+	}
+
+	//The same flashing for 4 seconds is fine
+	proc = createMemoryTestProcessor(createFlashingFrames(240, 15, 12, 5, 5), 30, assert)
+	err = proc.Process()
+	assert.NoError(err)
+	assert.Equalf(0, proc.HazardReport.Hazards.Len(), "Expected no hazards, got %d", proc.HazardReport.Hazards.Len())
+}
+
+func TestProcessorViewportCatchesSmallFlashingRegion(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//A 60x60 corner of a 512x384 frame is too small to change the brightness of a quarter of the screen,
+	//but it changes most of a quarter of a WCAG 171x128 viewport
+	white := color.RGBA{255, 255, 255, 255}
+
+	proc := processors.NewFlashingProcessor(createCornerFlashingSource(512, 384, 60, 60, 30, 5, 3, 3, white, assert), Test_Report_Directory)
+	err := proc.Process()
+	assert.NoError(err)
+	assert.Equalf(0, proc.HazardReport.Hazards.Len(), "Expected no hazards over the whole screen, got %d", proc.HazardReport.Hazards.Len())
+
+	proc = processors.NewFlashingProcessor(createCornerFlashingSource(512, 384, 60, 60, 30, 5, 3, 3, white, assert), Test_Report_Directory)
+	proc.Profile = profiles.WCAG231
+	err = proc.Process()
+	assert.NoError(err)
+	if assert.Equalf(1, proc.HazardReport.Hazards.Len(), "Expected 1 hazard in the viewport, got %d", proc.HazardReport.Hazards.Len()) {
+		assert.Equal(hazards.FlashHazard, proc.HazardReport.Hazards.Front().Value.(hazards.Hazard).HazardType)
+	}
+}
+
+func TestProcessorViewportCatchesSmallRedFlashingRegion(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//A 100x80 corner of a 512x384 frame is under 25% of the screen, but over 25% of a WCAG 171x128 viewport
+	red := color.RGBA{255, 0, 0, 255}
+
+	proc := processors.NewFlashingProcessor(createCornerFlashingSource(512, 384, 100, 80, 30, 5, 3, 3, red, assert), Test_Report_Directory)
+	proc.RedFlashes = true
+	err := proc.Process()
+	assert.NoError(err)
+	assert.Equalf(0, countHazards(proc.HazardReport, hazards.RedFlashHazard), "Expected no red flash hazards over the whole screen, got %d", countHazards(proc.HazardReport, hazards.RedFlashHazard))
+
+	proc = processors.NewFlashingProcessor(createCornerFlashingSource(512, 384, 100, 80, 30, 5, 3, 3, red, assert), Test_Report_Directory)
+	proc.Profile = profiles.WCAG231
+	proc.RedFlashes = true
+	err = proc.Process()
+	assert.NoError(err)
+	assert.Equalf(1, countHazards(proc.HazardReport, hazards.RedFlashHazard), "Expected 1 red flash hazard in the viewport, got %d", countHazards(proc.HazardReport, hazards.RedFlashHazard))
+}
+
+func TestProcessorReportsRedFlashesWithOtherHazards(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	//5 white flashes, then 5 flashes from black to saturated red and back in a second
+	black, red := color.RGBA{0, 0, 0, 255}, color.RGBA{255, 0, 0, 255}
+	frames := append(createFlashingFrames(90, 15, 5, 3, 3), createColorFlashingFrames(60, 5, 3, 3, black, red)...)
+
+	proc := createMemoryTestProcessor(frames, 30, assert)
+	err := proc.Process()
+	assert.NoError(err)
+	assert.Equalf(0, countHazards(proc.HazardReport, hazards.RedFlashHazard), "Expected no red flash hazards unless asked for")
+
+	emptyTestDirectory(assert)
+	proc = createMemoryTestProcessor(frames, 30, assert)
+	proc.RedFlashes = true
+	err = proc.Process()
+	assert.NoError(err)
+	assert.Equalf(1, countHazards(proc.HazardReport, hazards.RedFlashHazard), "Expected 1 red flash hazard")
+	assert.True(countHazards(proc.HazardReport, hazards.FlashHazard) > 0, "Expected the flash hazards too")
+
+	//Red flashes are part of the one report
+	files, err := ioutil.ReadDir(Test_Report_Directory)
+	assert.NoError(err)
+	var reports []string
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".json") {
+			reports = append(reports, file.Name())
+		}
+	}
+	if assert.Len(reports, 1) {
+		assert.True(strings.HasSuffix(reports[0], "-Report.json"), "Expected only Report.json, got %s", reports[0])
+		contents, err := ioutil.ReadFile(Test_Report_Directory + "/" + reports[0])
+		assert.NoError(err)
+		assert.Contains(string(contents), hazards.RedFlashHazard)
+	}
+}
+
+func TestProcessorIgnoresRedChangesWithinChromaticityDelta(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	//Both reds are close in chromaticity, but only the first is saturated red (R/(R+G+B) of 0.816 and 0.76)
+	saturated, unsaturated := color.RGBA{204, 23, 23, 255}, color.RGBA{190, 30, 30, 255}
+
+	proc := createMemoryTestProcessor(createColorFlashingFrames(60, 5, 3, 3, unsaturated, saturated), 30, assert)
+	proc.RedFlashes = true
+	err := proc.Process()
+	assert.NoError(err)
+	assert.Equalf(0, countHazards(proc.HazardReport, hazards.RedFlashHazard), "Expected no red flash hazards")
+}
+
+func TestProcessorIgnoresRedsUnderSaturatedRatio(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	black := color.RGBA{0, 0, 0, 255}
+
+	//R/(R+G+B) of 0.796 is just under 0.8
+	proc := createMemoryTestProcessor(createColorFlashingFrames(60, 5, 3, 3, black, color.RGBA{199, 26, 25, 255}), 30, assert)
+	proc.RedFlashes = true
+	err := proc.Process()
+	assert.NoError(err)
+	assert.Equalf(0, countHazards(proc.HazardReport, hazards.RedFlashHazard), "Expected no red flash hazards")
+
+	//R/(R+G+B) of 0.816 is just over
+	proc = createMemoryTestProcessor(createColorFlashingFrames(60, 5, 3, 3, black, color.RGBA{204, 23, 23, 255}), 30, assert)
+	proc.RedFlashes = true
+	err = proc.Process()
+	assert.NoError(err)
+	assert.Equalf(1, countHazards(proc.HazardReport, hazards.RedFlashHazard), "Expected 1 red flash hazard")
+}
+
+func TestProcessorReportsPatternsWithOtherHazards(t *testing.T) {
+	cases := []struct {
+		name    string
+		frame   decoder.Frame
+		hazards int
+	}{
+		{"stripes over PairsMax", createPatternFrame(8, 6, 96, false), 1},
+		{"stripes at PairsMax", createPatternFrame(8, 5, 96, false), 0},
+		{"stripes under PairsMax", createPatternFrame(8, 4, 96, false), 0},
+		{"checkerboard over PairsMax", createPatternFrame(8, 6, 96, true), 1},
+		{"checkerboard at PairsMax", createPatternFrame(8, 5, 96, true), 0},
+		{"checkerboard under PairsMax", createPatternFrame(8, 4, 96, true), 0},
+		//8 pairs 2 pixels wide cover a third of every row, but half the rows only cover a sixth of the screen
+		{"stripes over the area", createPatternFrame(2, 8, 96, false), 1},
+		{"stripes under the area", createPatternFrame(2, 8, 48, false), 0},
+	}
+
+	for _, c := range cases {
+		assert := assert.New(t)
+
+		frames := make([]decoder.Frame, 10)
+		for i := range frames {
+			frames[i] = c.frame
+		}
+
+		proc := createMemoryTestProcessor(frames, 30, assert)
+		proc.Patterns = true
+		err := proc.Process()
+		assert.NoError(err)
+		assert.Equalf(c.hazards, countHazards(proc.HazardReport, hazards.PatternHazard), "Expected %d pattern hazards for %s", c.hazards, c.name)
+		assert.Equalf(c.hazards, proc.HazardReport.Hazards.Len(), "Expected only pattern hazards for %s", c.name)
+		emptyTestDirectory(assert)
+	}
+}
+
+func TestProcessorLocatesFlashingRegion(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//Only the top left quarter of the screen flashes
+	source := createCornerFlashingSource(128, 96, 64, 48, 30, 5, 3, 3, color.RGBA{255, 255, 255, 255}, assert)
+	proc := processors.NewFlashingProcessor(source, Test_Report_Directory)
+	proc.Heatmaps = true
+	err := proc.Process()
+	assert.NoError(err)
+
+	if assert.Equalf(1, proc.HazardReport.Hazards.Len(), "Expected 1 hazard, got %d", proc.HazardReport.Hazards.Len()) {
+		hazard := proc.HazardReport.Hazards.Front().Value.(hazards.Hazard)
+		assert.Equal([]hazards.Region{{X: 0, Y: 0, Width: 64, Height: 48}}, hazard.Regions)
+
+		heatmap, err := os.Open(hazard.Heatmap)
+		if assert.NoError(err) {
+			defer heatmap.Close()
+			img, err := png.Decode(heatmap)
+			assert.NoError(err)
+			assert.Equal(image.Rect(0, 0, 128, 96), img.Bounds())
+			_, _, _, alpha := img.At(10, 10).RGBA()
+			assert.NotEqual(uint32(0), alpha)
+			_, _, _, alpha = img.At(100, 80).RGBA()
+			assert.Equal(uint32(0), alpha)
+		}
+	}
+}
+
+func TestProcessorExportsHazardEvidence(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	frames := createFlashingFrames(90, 15, 5, 3, 3)
+	proc := createMemoryTestProcessor(frames, 30, assert)
+	proc.Evidence = func(ctx context.Context) (decoder.FrameSource, error) {
+		source := decoder.NewMemorySource("memory.mp4", frames, 30)
+		return &source, nil
+	}
+	err := proc.Process()
+	assert.NoError(err)
+
+	if !assert.Equalf(1, proc.HazardReport.Hazards.Len(), "Expected 1 hazard, got %d", proc.HazardReport.Hazards.Len()) {
+		return
+	}
+	hazard := proc.HazardReport.Hazards.Front().Value.(hazards.Hazard)
+
+	//The first flash is the biggest change, from the black frame 14 to the white frame 15
+	if assert.Len(hazard.Evidence, 2) {
+		assert.Contains(hazard.Evidence[0], "Evidence-Flash-1-Frame-14")
+		for i, brightness := range []uint32{0, 0xffff} {
+			img := decodeTestPNG(hazard.Evidence[i], assert)
+			if img != nil {
+				assert.Equal(image.Rect(0, 0, 32, 24), img.Bounds())
+				red, _, _, _ := img.At(16, 12).RGBA()
+				assert.Equal(brightness, red)
+			}
+		}
+	}
+
+	//Frames 9 to 20 in a 4x3 grid of 240x180 frames, the change is in the middle
+	sheet := decodeTestPNG(hazard.ContactSheet, assert)
+	if sheet != nil {
+		assert.Equal(image.Rect(0, 0, 980, 556), sheet.Bounds())
+		white := color.RGBA{255, 255, 255, 255}
+		assert.Equal(color.RGBA{255, 0, 0, 255}, color.RGBAModel.Convert(sheet.At(489, 300)))
+		assert.Equal(white, color.RGBAModel.Convert(sheet.At(850, 300)))
+
+		//Frame 9 is black, but its label isn't
+		assert.Equal(color.RGBA{0, 0, 0, 255}, color.RGBAModel.Convert(sheet.At(100, 100)))
+		assert.Equal(white, color.RGBAModel.Convert(sheet.At(8, 8)))
+	}
+}
+
+func TestProcessorExportsSelfContainedHTMLReport(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//Flashes to saturated red are both flashes and red flashes
+	frames := append(createFlashingFrames(90, 15, 5, 3, 3), createColorFlashingFrames(60, 5, 3, 3, color.RGBA{0, 0, 0, 255}, color.RGBA{255, 0, 0, 255})...)
+	proc := createMemoryTestProcessor(frames, 30, assert)
+	proc.RedFlashes = true
+	proc.HTMLReport = true
+	proc.Evidence = func(ctx context.Context) (decoder.FrameSource, error) {
+		source := decoder.NewMemorySource("memory.mp4", frames, 30)
+		return &source, nil
+	}
+	assert.NoError(proc.Process())
+
+	files, err := ioutil.ReadDir(Test_Report_Directory)
+	assert.NoError(err)
+
+	var page string
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "-Report.html") {
+			contents, err := ioutil.ReadFile(Test_Report_Directory + "/" + file.Name())
+			assert.NoError(err)
+			page = string(contents)
+		}
+	}
+
+	if assert.NotEqual("", page, "Expected an HTML report") {
+		assert.Contains(page, "<svg")
+		assert.Contains(page, "00:00:00:15")
+		assert.Contains(page, `data-hazard="1"`)
+		assert.Contains(page, `class="hazard redflash"`)
+		assert.Contains(page, "data:image/png;base64,")
+		assert.False(strings.Contains(page, "http"), "The report shouldn't need the network")
+	}
+}
+
+func TestProcessorExportsCuesWithTheReport(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	proc := createMemoryTestProcessor(createFlashingFrames(90, 15, 5, 3, 3), 30, assert)
+	proc.Cues = true
+	assert.NoError(proc.Process())
+
+	files, err := ioutil.ReadDir(Test_Report_Directory)
+	assert.NoError(err)
+
+	//The cues are named like the rest of the report
+	prefixes := make(map[string]string)
+	for _, file := range files {
+		for _, suffix := range []string{"-Report.json", "-Hazards.vtt", "-Hazards.srt"} {
+			if strings.HasSuffix(file.Name(), suffix) {
+				prefixes[suffix] = strings.TrimSuffix(file.Name(), suffix)
+			}
+		}
+	}
+
+	if assert.Len(prefixes, 3, "Expected a report and both cue tracks") {
+		assert.Equal(prefixes["-Report.json"], prefixes["-Hazards.vtt"])
+		assert.Equal(prefixes["-Report.json"], prefixes["-Hazards.srt"])
+	}
+}
+
+func TestProcessorTimelineStaysTheSameSizeForLongVideos(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	//Over 10 minutes of flashing, three flashes a second
+	proc := createMemoryTestProcessor(createFlashingFrames(20000, 0, 2000, 5, 5), 30, assert)
+	proc.RecordTimeline = true
+	proc.SkipExport = true
+	assert.NoError(proc.Process())
+
+	//Every column of the chart keeps at most its lowest, highest and last value twice over
+	assert.True(len(proc.Timeline.Accumulation) <= 6*888, "Expected the accumulation to be downsampled, got %d points", len(proc.Timeline.Accumulation))
+	assert.True(len(proc.Timeline.FlashFrequency) <= 6*888, "Expected the flash frequency to be downsampled, got %d points", len(proc.Timeline.FlashFrequency))
+
+	last := proc.Timeline.Accumulation[len(proc.Timeline.Accumulation)-1]
+	assert.Equal(19999*time.Second/30, last.Time, "Expected the last frame to be kept")
+}
+
+//decodeTestPNG opens and decodes the PNG at path, nil if it can't
+func decodeTestPNG(path string, assert *assert.Assertions) image.Image {
+	file, err := os.Open(path)
+	if !assert.NoError(err) {
+		return nil
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if !assert.NoError(err) {
+		return nil
+	}
+	return img
+}
+
+func TestFlashingProcessorsRunConcurrently(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//Run with -race, analyses running side by side in a server share the brightness lookup
+	videos := [][]decoder.Frame{
+		createFlashingFrames(90, 15, 5, 3, 3),
+		createFlashingFrames(90, 15, 2, 3, 3),
+	}
+	expectedHazards := []int{1, 0}
+	processorList := make([]processors.FlashingProcessor, len(videos))
+	errs := make([]error, len(videos))
+
+	var wg sync.WaitGroup
+	for i, frames := range videos {
+		source := decoder.NewMemorySource("concurrent"+strconv.Itoa(i)+".mp4", frames, 30)
+		processorList[i] = processors.NewFlashingProcessor(&source, Test_Report_Directory)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = processorList[i].Process()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range videos {
+		assert.NoError(errs[i])
+		assert.Equalf(expectedHazards[i], processorList[i].HazardReport.Hazards.Len(), "Expected %d hazards in video %d", expectedHazards[i], i)
+	}
+}
+
+func TestProcessorStopsWhenContextIsCancelled(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	proc := createMemoryTestProcessor(createFlashingFrames(90, 15, 5, 3, 3), 30, assert)
+	err := proc.ProcessContext(ctx)
+	assert.ErrorIs(err, context.Canceled)
+
+	proc = createMemoryTestProcessor(createFlashingFrames(90, 15, 5, 3, 3), 30, assert)
+	proc.RedFlashes = true
+	proc.Patterns = true
+	err = proc.ProcessContext(ctx)
+	assert.ErrorIs(err, context.Canceled)
+}
+
+func TestProcessorRejectsTruncatedVideo(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//The last frame is cut short, so the video was never analyzed to the end
+	stream := createY4MStream("YUV4MPEG2 W4 H2 F25:1 C420jpeg", 16, 235, 16, 235)
+	stream.Truncate(stream.Len() - 1)
+	source := decoder.NewY4MSourceFromReader("truncated.y4m", stream)
+	assert.NoError(source.Start())
+
+	proc := processors.NewFlashingProcessor(&source, Test_Report_Directory)
+	err := proc.Process()
+	assert.ErrorIs(err, decoder.ErrTruncated)
+}
+
+func TestProcessorReportsProgress(t *testing.T) {
+	assert := assert.New(t)
+	proc := createMemoryTestProcessor(createFlashingFrames(90, 15, 5, 3, 3), 30, assert)
+	defer emptyTestDirectory(assert)
+
+	updates := make([]processors.Progress, 0)
+	proc.Progress = func(progress processors.Progress) {
+		updates = append(updates, progress)
+	}
+
+	assert.NoError(proc.Process())
+	if !assert.True(len(updates) > 0) {
+		return
+	}
+
+	//Every frame is counted and the last update says so
+	last := updates[len(updates)-1]
+	assert.True(last.Done)
+	assert.Equal(90, last.Frames)
+	assert.Equal(90, last.TotalFrames)
+	assert.Equal(3*time.Second, last.Duration)
+	assert.InDelta(100, float64(last.Percent), 0.01)
+	assert.Equal(time.Duration(0), last.ETA)
+	assert.Equal(proc.HazardReport.Hazards.Len(), last.Hazards)
+	assert.Equal(1, last.Hazards)
+}
+
+//countingSource counts how many frames have been read from it, reading stalls at frame gate until release is closed
+type countingSource struct {
+	*decoder.MemorySource
+	read    int32
+	gate    int32
+	release chan struct{}
+}
+
+func (s *countingSource) NextFrame() (decoder.Frame, error) {
+	if atomic.AddInt32(&s.read, 1) == s.gate {
+		select {
+		case <-s.release:
+		case <-time.After(5 * time.Second):
+		}
+	}
+	return s.MemorySource.NextFrame()
+}
+
+func TestProcessorStreamsHazardsAsTheyFinish(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//Flashing in the first two seconds, then the brightness wavers too little to flash for eight more
+	frames := createFlashingFrames(60, 15, 5, 3, 3)
+	for i := 0; i < 240; i++ {
+		frames = append(frames, createSolidFrame(32, 24, byte(30*(i%2))))
+	}
+	memory := decoder.NewMemorySource("memory.mp4", frames, 30)
+	source := &countingSource{MemorySource: &memory, gate: 150, release: make(chan struct{})}
+
+	found := make(chan hazards.Hazard)
+	proc := processors.NewFlashingProcessor(source, Test_Report_Directory)
+	proc.Hazards = found
+
+	streamed := make([]hazards.Hazard, 0)
+	framesRead := make([]int32, 0)
+	done := make(chan struct{})
+	go func() {
+		for hazard := range found {
+			streamed = append(streamed, hazard)
+			framesRead = append(framesRead, atomic.LoadInt32(&source.read))
+			if len(streamed) == 1 {
+				close(source.release)
+			}
+		}
+		close(done)
+	}()
+
+	err := proc.Process()
+	close(found)
+	<-done
+	assert.NoError(err)
+
+	//The hazard is sent long before the video ends, and matches the report
+	if !assert.Len(streamed, 1) {
+		return
+	}
+	assert.True(framesRead[0] <= 150)
+	assert.Equal(proc.HazardReport.Hazards.Front().Value.(hazards.Hazard), streamed[0])
+	assert.Equal(uint(15), streamed[0].StartFrame)
+}
+
+//collectAlerts analyzes frames at 30fps and returns every alert raised and the hazard report
+func collectAlerts(frames []decoder.Frame, early bool, assert *assert.Assertions) ([]processors.Alert, hazards.HazardReport) {
+	memory := decoder.NewMemorySource("memory.mp4", frames, 30)
+	raised := make(chan processors.Alert)
+	proc := processors.NewFlashingProcessor(&memory, Test_Report_Directory)
+	proc.Alerts = raised
+	proc.EarlyAlerts = early
+	proc.SkipExport = true
+
+	alerts := make([]processors.Alert, 0)
+	done := make(chan struct{})
+	go func() {
+		for alert := range raised {
+			alerts = append(alerts, alert)
+		}
+		close(done)
+	}()
+
+	err := proc.Process()
+	close(raised)
+	<-done
+	assert.NoError(err)
+	return alerts, proc.HazardReport
+}
+
+func TestProcessorAlertsAsSoonAsFlashingIsHazardous(t *testing.T) {
+	assert := assert.New(t)
+
+	//Five flashes a second for two seconds
+	frames := createFlashingFrames(150, 15, 10, 3, 3)
+	alerts, report := collectAlerts(frames, false, assert)
+	if !assert.Len(alerts, 1) || !assert.Equal(1, report.Hazards.Len()) {
+		return
+	}
+
+	//Raised within a second of the flashing starting, long before the hazard is over
+	alert := alerts[0]
+	hazard := report.Hazards.Front().Value.(hazards.Hazard)
+	assert.Equal(hazards.FlashHazard, alert.HazardType)
+	assert.Equal(hazard.StartFrame, alert.StartFrame)
+	assert.True(alert.Time-alert.StartTime < time.Second)
+	assert.True(alert.Frame < hazard.EndFrame)
+	assert.True(alert.Flashes > 3)
+	assert.False(alert.Provisional)
+
+	//Counting the transition in progress raises it sooner
+	earlyAlerts, _ := collectAlerts(frames, true, assert)
+	if assert.Len(earlyAlerts, 1) {
+		assert.True(earlyAlerts[0].Frame < alert.Frame)
+		assert.True(earlyAlerts[0].Provisional)
+	}
+
+	//Flashing too slowly to be hazardous raises nothing
+	safeAlerts, _ := collectAlerts(createFlashingFrames(150, 15, 4, 8, 8), true, assert)
+	assert.Len(safeAlerts, 0)
+
+	//Each hazard gets its own alert
+	separate := append(createFlashingFrames(90, 15, 5, 3, 3), createFlashingFrames(90, 15, 5, 3, 3)...)
+	separateAlerts, separateReport := collectAlerts(separate, false, assert)
+	assert.Equal(2, separateReport.Hazards.Len())
+	assert.Len(separateAlerts, 2)
+}
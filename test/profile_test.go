@@ -0,0 +1,113 @@
+package test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/lycerius/epilguard/profiles"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileLookupIgnoresCase(t *testing.T) {
+	assert := assert.New(t)
+
+	profile, ok := profiles.Lookup("wcag-2.3.1")
+	assert.True(ok)
+	assert.Equal(profiles.WCAG231.Name, profile.Name)
+
+	_, ok = profiles.Lookup("not-a-standard")
+	assert.False(ok)
+}
+
+func TestProfileLoadKeepsDefaultsForMissingRules(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	path := filepath.Join(Test_Report_Directory, "strict.json")
+	err := ioutil.WriteFile(path, []byte(`{"frequency":{"flashesPerSecondMax":2}}`), 0644)
+	assert.NoError(err)
+
+	profile, err := profiles.Find(path)
+	assert.NoError(err)
+	assert.Equal("strict", profile.Name)
+	assert.Equal(2, profile.Frequency.FlashesPerSecondMax)
+	assert.Equal(profiles.BT1702.Luminance, profile.Luminance)
+	assert.Equal(profiles.BT1702.Area, profile.Area)
+}
+
+func TestProfileLoadRejectsInvalidArea(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	path := filepath.Join(Test_Report_Directory, "broken.json")
+	err := ioutil.WriteFile(path, []byte(`{"area":{"fraction":2}}`), 0644)
+	assert.NoError(err)
+
+	_, err = profiles.Load(path)
+	assert.Error(err)
+}
+
+func TestProfileLoadRejectsInvalidRules(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	tests := map[string]string{
+		"zero viewport height":        `{"area":{"fraction":0.25,"viewportWidth":341,"viewportHeight":0,"referenceWidth":1024,"referenceHeight":768}}`,
+		"negative viewport height":    `{"area":{"fraction":0.25,"viewportWidth":341,"viewportHeight":-256,"referenceWidth":1024,"referenceHeight":768}}`,
+		"negative viewport width":     `{"area":{"fraction":0.25,"viewportWidth":-341,"viewportHeight":256,"referenceWidth":1024,"referenceHeight":768}}`,
+		"negative flash delta":        `{"luminance":{"flashDelta":-20}}`,
+		"negative dark brightness":    `{"luminance":{"darkBrightnessMax":-160}}`,
+		"negative chromaticity delta": `{"red":{"chromaticityDelta":-0.2}}`,
+		"zero saturated ratio":        `{"red":{"saturatedRatio":0}}`,
+		"saturated ratio above 1":     `{"red":{"saturatedRatio":1.5}}`,
+	}
+
+	for name, rules := range tests {
+		path := filepath.Join(Test_Report_Directory, "broken.json")
+		err := ioutil.WriteFile(path, []byte(rules), 0644)
+		assert.NoError(err)
+
+		_, err = profiles.Load(path)
+		assert.Errorf(err, "Expected %s to be rejected", name)
+	}
+}
+
+func TestBuiltInProfilesAreValid(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, profile := range profiles.BuiltIn {
+		assert.NoErrorf(profile.Validate(), "Expected %s to be valid", profile.Name)
+	}
+
+	//Ofcom follows the ITU-R thresholds but also limits extended flashing
+	assert.Equal(profiles.BT1702.Luminance, profiles.Ofcom.Luminance)
+	assert.Equal(profiles.BT1702.Frequency.FlashesPerSecondMax, profiles.Ofcom.Frequency.FlashesPerSecondMax)
+	assert.Equal(float32(0), profiles.BT1702.Frequency.ExtendedSecondsMax)
+	assert.Equal(float32(5), profiles.Ofcom.Frequency.ExtendedSecondsMax)
+}
+
+func TestProcessorUsesProfile(t *testing.T) {
+	assert := assert.New(t)
+	defer emptyTestDirectory(assert)
+
+	//WCAG 2.3.1 has no limit on how long flashing may continue
+	proc := createMemoryTestProcessor(createFlashingFrames(240, 15, 21, 5, 5), 30, assert)
+	proc.Profile = profiles.WCAG231
+	err := proc.Process()
+	assert.NoError(err)
+	assert.Equal(profiles.WCAG231.Name, proc.HazardReport.Profile)
+	assert.Equalf(0, proc.HazardReport.Hazards.Len(), "Expected no hazards, got %d", proc.HazardReport.Hazards.Len())
+
+	//A stricter profile does not allow 3 flashes per second
+	strict := profiles.Default()
+	strict.Frequency.FlashesPerSecondMax = 2
+	proc = createMemoryTestProcessor(createFlashingFrames(90, 15, 3, 5, 5), 30, assert)
+	proc.Profile = strict
+	err = proc.Process()
+	assert.NoError(err)
+	assert.Equalf(1, proc.HazardReport.Hazards.Len(), "Expected 1 hazard, got %d", proc.HazardReport.Hazards.Len())
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lycerius/epilguard/hazards"
+	"github.com/lycerius/epilguard/processors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimecodeIsFrameAccurate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("00:00:00:00", hazards.Timecode(0, 30))
+	assert.Equal("00:00:01:15", hazards.Timecode(1500*time.Millisecond, 30))
+	assert.Equal("01:01:01:23", hazards.Timecode(time.Hour+time.Minute+time.Second+23*time.Second/24, 24))
+}
+
+func TestHazardCarriesFramesAndTimes(t *testing.T) {
+	assert := assert.New(t)
+
+	hazard := hazards.NewHazard("Flash", 45, 89, 1500*time.Millisecond, 3*time.Second, 30)
+
+	assert.Equal(uint(45), hazard.StartFrame)
+	assert.Equal(uint(89), hazard.EndFrame)
+	assert.Equal(uint(1), hazard.Start)
+	assert.Equal(uint(3), hazard.End)
+	assert.InDelta(1.5, hazard.StartTime, 1e-9)
+	assert.InDelta(3.0, hazard.EndTime, 1e-9)
+	assert.InDelta(1.5, hazard.Duration, 1e-9)
+	assert.Equal("00:00:01:15", hazard.StartTimecode)
+	assert.Equal("00:00:03:00", hazard.EndTimecode)
+}
+
+func TestHazardReportLeavesOutEmptyRegions(t *testing.T) {
+	assert := assert.New(t)
+
+	var report hazards.HazardReport
+	report.Hazards.PushBack(hazards.NewHazard(hazards.FlashHazard, 0, 30, 0, time.Second, 30))
+	located := hazards.NewHazard(hazards.FlashHazard, 0, 30, 0, time.Second, 30)
+	located.Regions = []hazards.Region{{X: 1, Y: 2, Width: 3, Height: 4}}
+	report.Hazards.PushBack(located)
+
+	json, err := report.MarshalJSON()
+	assert.NoError(err)
+	assert.False(strings.Contains(string(json), `"regions":null`), "Hazards without regions shouldn't have null regions")
+	assert.Contains(string(json), `"regions":[{"x":1,"y":2,"width":3,"height":4}]`)
+}
+
+func TestHazardSeverity(t *testing.T) {
+	assert := assert.New(t)
+
+	//Every type of hazard fails the standard, however long it lasts
+	hazardTypes := []string{hazards.FlashHazard, hazards.RedFlashHazard, hazards.ExtendedFlashHazard, hazards.PatternHazard}
+
+	for _, hazardType := range hazardTypes {
+		short := hazards.NewHazard(hazardType, 0, 30, 0, time.Second, 30)
+		long := hazards.NewHazard(hazardType, 0, 18000, 0, 10*time.Minute, 30)
+		assert.Equal(hazards.SeverityHigh, short.Severity(), hazardType)
+		assert.Equal(hazards.SeverityHigh, long.Severity(), hazardType)
+	}
+}
+
+func TestHazardCuesAreFrameAccurate(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//Frames 31 to 57 at 30fps, the last frame stops being shown when frame 58 is, and frame 3 is shown at exactly 0.1 seconds
+	var report hazards.HazardReport
+	report.Hazards.PushBack(hazards.NewHazard(hazards.FlashHazard, 31, 57, 31*time.Second/30, 58*time.Second/30, 30))
+	report.Hazards.PushBack(hazards.NewHazard(hazards.PatternHazard, 3, 3, 3*time.Second/30, 4*time.Second/30, 30))
+
+	now := time.Now()
+	assert.NoError(processors.ExportWebVTTCues("cues.mp4", Test_Report_Directory, report, now))
+	assert.NoError(processors.ExportSRTCues("cues.mp4", Test_Report_Directory, report, now))
+
+	vtt := readTestReport("Hazards.vtt", assert)
+	assert.True(strings.HasPrefix(vtt, "WEBVTT\n\n"), "WebVTT files start with WEBVTT")
+	assert.Contains(vtt, "hazard-1\n00:00:00.100 --> 00:00:00.133\nPhotosensitivity warning: Regular pattern, high severity\n")
+	assert.Contains(vtt, "hazard-2\n00:00:01.033 --> 00:00:01.933\nPhotosensitivity warning: Flashing, high severity\n")
+
+	srt := readTestReport("Hazards.srt", assert)
+	assert.True(strings.HasPrefix(srt, "1\n00:00:00,100 --> 00:00:00,133\n"), "Cues are in the order they start")
+	assert.Contains(srt, "2\n00:00:01,033 --> 00:00:01,933\nPhotosensitivity warning: Flashing, high severity\n")
+
+	//Failing to write a track is an error
+	assert.Error(processors.ExportWebVTTCues("cues.mp4", Test_Report_Directory+"/missing", report, now))
+	assert.Error(processors.ExportSRTCues("cues.mp4", Test_Report_Directory+"/missing", report, now))
+}
+
+//readTestReport reads the report file in the test directory whose name ends with suffix
+func readTestReport(suffix string, assert *assert.Assertions) string {
+	files, err := ioutil.ReadDir(Test_Report_Directory)
+	assert.NoError(err)
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), suffix) {
+			contents, err := ioutil.ReadFile(filepath.Join(Test_Report_Directory, file.Name()))
+			assert.NoError(err)
+			return string(contents)
+		}
+	}
+
+	assert.FailNow("No report ending with " + suffix)
+	return ""
+}
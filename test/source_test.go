@@ -0,0 +1,319 @@
+package test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lycerius/epilguard/decoder"
+	"github.com/lycerius/epilguard/equations"
+	"github.com/lycerius/epilguard/processors"
+	"github.com/stretchr/testify/assert"
+)
+
+//createY4MStream creates a 4x2 4:2:0 stream where every frame has one of lumas as its luma
+func createY4MStream(header string, lumas ...byte) *bytes.Buffer {
+	var stream bytes.Buffer
+	stream.WriteString(header + "\n")
+	for _, luma := range lumas {
+		stream.WriteString("FRAME\n")
+		stream.Write(bytes.Repeat([]byte{luma}, 4*2))
+		stream.Write(bytes.Repeat([]byte{128}, 2*1*2))
+	}
+	return &stream
+}
+
+func TestY4MSourceParsesHeader(t *testing.T) {
+	assert := assert.New(t)
+	stream := createY4MStream("YUV4MPEG2 W4 H2 F30000:1001 It A1:1 C420jpeg XCOLORRANGE=FULL")
+	source := decoder.NewY4MSourceFromReader("test.y4m", stream)
+
+	assert.NoError(source.Start())
+
+	width, height := source.Dimensions()
+	assert.Equal(4, width)
+	assert.Equal(2, height)
+	assert.Equal(30, source.FrameRate())
+	assert.Equal("t", source.Interlacing)
+	assert.Equal("420jpeg", source.Colorspace)
+	assert.True(source.FullRange)
+}
+
+func TestY4MSourceReadsLumaDirectly(t *testing.T) {
+	assert := assert.New(t)
+	stream := createY4MStream("YUV4MPEG2 W4 H2 F30000:1001 Ip C420jpeg", 16, 235)
+	source := decoder.NewY4MSourceFromReader("test.y4m", stream)
+	assert.NoError(source.Start())
+
+	first, err := source.NextFrame()
+	assert.NoError(err)
+	second, err := source.NextFrame()
+	assert.NoError(err)
+	_, err = source.NextFrame()
+	assert.Error(err)
+	assert.Equal("EOF", err.Error())
+
+	//Limited range is expanded to full range
+	assert.Equal(bytes.Repeat([]byte{0}, 8), first.Luma())
+	assert.Equal(bytes.Repeat([]byte{255}, 8), second.Luma())
+	assert.Equal(decoder.Pixel{Red: 255, Green: 255, Blue: 255}, second.GetRGB(3, 1))
+
+	assert.Equal(uint(1), second.Index)
+	assert.Equal(time.Duration(1001)*time.Second/30000, second.Timestamp)
+}
+
+func TestLumaMatchesTheLumaOfRGB(t *testing.T) {
+	assert := assert.New(t)
+
+	//Reading the Y plane directly gives the same brightness as converting the frame to RGB
+	colors := []color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}, {255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {200, 120, 40, 255}}
+	for _, c := range colors {
+		y, _, _ := color.RGBToYCbCr(c.R, c.G, c.B)
+		assert.Equal(equations.LumaToBrightness(int(y)), equations.RGBtoBrightness(int(c.R), int(c.G), int(c.B)), "%v", c)
+	}
+}
+
+func TestY4MSourceRejectsTruncatedFrame(t *testing.T) {
+	assert := assert.New(t)
+	stream := createY4MStream("YUV4MPEG2 W4 H2 F25:1", 16)
+	stream.Truncate(stream.Len() - 1)
+	source := decoder.NewY4MSourceFromReader("test.y4m", stream)
+	assert.NoError(source.Start())
+
+	_, err := source.NextFrame()
+	assert.Error(err)
+	assert.NotEqual("EOF", err.Error())
+	assert.ErrorIs(err, decoder.ErrTruncated)
+	assert.ErrorIs(err, io.ErrUnexpectedEOF)
+}
+
+//createGIFStream encodes a 2x2 GIF where every frame is filled with one of the colors
+func createGIFStream(t *assert.Assertions, loopCount int, delays []int, disposals []byte, colors ...color.Color) *bytes.Buffer {
+	palette := color.Palette{color.Transparent, color.Black, color.White}
+	animation := gif.GIF{LoopCount: loopCount, Delay: delays, Disposal: disposals}
+	animation.Config = image.Config{ColorModel: palette, Width: 2, Height: 2}
+
+	for _, c := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, 2, 2), palette)
+		for i := range frame.Pix {
+			frame.Pix[i] = uint8(palette.Index(c))
+		}
+		animation.Image = append(animation.Image, frame)
+	}
+
+	var stream bytes.Buffer
+	t.NoError(gif.EncodeAll(&stream, &animation))
+	return &stream
+}
+
+//readAllFrames reads every frame from a source until it fails
+func readAllFrames(source decoder.FrameSource) []decoder.Frame {
+	frames := make([]decoder.Frame, 0)
+	for frame, err := source.NextFrame(); err == nil; frame, err = source.NextFrame() {
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func TestGIFSourceExpandsDelaysToConstantRate(t *testing.T) {
+	assert := assert.New(t)
+	stream := createGIFStream(assert, -1, []int{5, 10}, nil, color.Black, color.White)
+	source := decoder.NewGIFSourceFromReader("test.gif", stream)
+	assert.NoError(source.Start())
+
+	assert.Equal(20, source.FrameRate())
+
+	frames := readAllFrames(&source)
+	assert.Len(frames, 3)
+	assert.Equal(0, frames[0].GetRGB(0, 0).Red)
+	assert.Equal(255, frames[1].GetRGB(0, 0).Red)
+	assert.Equal(255, frames[2].GetRGB(0, 0).Red)
+	assert.Equal(100*time.Millisecond, frames[2].Timestamp)
+}
+
+func TestGIFSourceKeepsDelaysThatDontDivideASecond(t *testing.T) {
+	assert := assert.New(t)
+	stream := createGIFStream(assert, -1, []int{14, 14}, nil, color.Black, color.White)
+	source := decoder.NewGIFSourceFromReader("test.gif", stream)
+	assert.NoError(source.Start())
+
+	//A 14 hundredths delay is 7 frames at 50 frames per second, at 7 frames per second the timeline would run long
+	assert.Equal(50, source.FrameRate())
+
+	frames := readAllFrames(&source)
+	assert.Len(frames, 14)
+	assert.Equal(0, frames[6].GetRGB(0, 0).Red)
+	assert.Equal(255, frames[7].GetRGB(0, 0).Red)
+	assert.Equal(140*time.Millisecond, frames[7].Timestamp)
+}
+
+//delayedSource stamps every frame of a memory source with a constant delay, which doesn't have to be a whole frame rate
+type delayedSource struct {
+	*decoder.MemorySource
+	delay time.Duration
+}
+
+func (s *delayedSource) NextFrame() (decoder.Frame, error) {
+	frame, err := s.MemorySource.NextFrame()
+	frame.Timestamp = time.Duration(frame.Index) * s.delay
+	return frame, err
+}
+
+func TestGIFSourceFindsFlashesLikeExactTimestamps(t *testing.T) {
+	assert := assert.New(t)
+	createTestDirectory(assert)
+	defer emptyTestDirectory(assert)
+
+	//16 black and white frames shown for 14 hundredths of a second each flash a little over 3 times a second
+	delays := make([]int, 16)
+	colors := make([]color.Color, 16)
+	frames := make([]decoder.Frame, 16)
+	for i := range delays {
+		delays[i] = 14
+		colors[i] = color.Black
+		frames[i] = createSolidFrame(2, 2, 0)
+		if i%2 == 1 {
+			colors[i] = color.White
+			frames[i] = createSolidFrame(2, 2, 255)
+		}
+	}
+
+	memory := decoder.NewMemorySource("memory.gif", frames, 7)
+	exact := processors.NewFlashingProcessor(&delayedSource{&memory, 140 * time.Millisecond}, Test_Report_Directory)
+	exact.SkipExport = true
+	assert.NoError(exact.Process())
+
+	stream := createGIFStream(assert, -1, delays, nil, colors...)
+	source := decoder.NewGIFSourceFromReader("test.gif", stream)
+	assert.NoError(source.Start())
+	proc := processors.NewFlashingProcessor(&source, Test_Report_Directory)
+	proc.SkipExport = true
+	assert.NoError(proc.Process())
+
+	assert.Equal(1, exact.HazardReport.Hazards.Len())
+	assert.Equal(exact.HazardReport.Hazards.Len(), proc.HazardReport.Hazards.Len())
+}
+
+func TestGIFSourceHonorsLoopCount(t *testing.T) {
+	assert := assert.New(t)
+
+	stream := createGIFStream(assert, 2, []int{10, 10}, nil, color.Black, color.White)
+	source := decoder.NewGIFSourceFromReader("test.gif", stream)
+	assert.NoError(source.Start())
+	assert.Len(readAllFrames(&source), 6)
+
+	stream = createGIFStream(assert, 0, []int{10, 10}, nil, color.Black, color.White)
+	source = decoder.NewGIFSourceFromReader("test.gif", stream)
+	source.InfiniteLoopPlays = 3
+	assert.NoError(source.Start())
+	assert.Len(readAllFrames(&source), 6)
+}
+
+func TestGIFSourceHonorsDisposal(t *testing.T) {
+	assert := assert.New(t)
+	disposals := []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone}
+	stream := createGIFStream(assert, -1, []int{10, 10, 10}, disposals, color.White, color.White, color.Transparent)
+	source := decoder.NewGIFSourceFromReader("test.gif", stream)
+	assert.NoError(source.Start())
+
+	frames := readAllFrames(&source)
+	assert.Len(frames, 3)
+
+	//The second frame is cleared after being shown, so nothing is left under the transparent third frame
+	assert.Equal(255, frames[1].GetRGB(0, 0).Red)
+	assert.Equal(0, frames[2].GetRGB(0, 0).Red)
+}
+
+func TestFrameAddressesEveryPixel(t *testing.T) {
+	assert := assert.New(t)
+
+	//Every byte of a 3x2 frame is different, so reading from the wrong place can't go unnoticed
+	pixels := make([]byte, 3*2*3)
+	for i := range pixels {
+		pixels[i] = byte(i * 10)
+	}
+	frame := decoder.NewFrame(3, 2, pixels)
+
+	assert.Equal(decoder.Pixel{Red: 0, Green: 10, Blue: 20}, frame.GetRGB(0, 0))
+	assert.Equal(decoder.Pixel{Red: 60, Green: 70, Blue: 80}, frame.GetRGB(2, 0))
+	assert.Equal(decoder.Pixel{Red: 90, Green: 100, Blue: 110}, frame.GetRGB(0, 1))
+	assert.Equal(decoder.Pixel{Red: 120, Green: 130, Blue: 140}, frame.GetRGB(1, 1))
+	assert.Equal(decoder.Pixel{Red: 150, Green: 160, Blue: 170}, frame.GetRGB(2, 1))
+
+	assert.Equal([]byte{90, 100, 110, 120, 130, 140, 150, 160, 170}, frame.Row(1))
+	assert.Equal(pixels, frame.Pixels())
+}
+
+func TestFrameConvertsYUVRows(t *testing.T) {
+	assert := assert.New(t)
+	source := decoder.NewY4MSourceFromReader("test.y4m", createY4MStream("YUV4MPEG2 W4 H2 F30:1 C420jpeg", 200))
+	assert.NoError(source.Start())
+
+	frame, err := source.NextFrame()
+	if !assert.NoError(err) {
+		return
+	}
+
+	//Rows and pixels hold the same colors GetRGB converts one at a time
+	row := frame.Row(1)
+	pixels := frame.Pixels()
+	assert.Len(row, 4*3)
+	assert.Len(pixels, 4*2*3)
+	for x := 0; x < 4; x++ {
+		pixel := frame.GetRGB(x, 1)
+		assert.Equal([]byte{byte(pixel.Red), byte(pixel.Green), byte(pixel.Blue)}, row[x*3:x*3+3])
+		assert.Equal(row[x*3:x*3+3], pixels[(4+x)*3:(4+x)*3+3])
+	}
+	assert.NotEqual(byte(200), row[0])
+}
+
+func TestFrameRoundTripsThroughPNG(t *testing.T) {
+	assert := assert.New(t)
+
+	pixels := make([]byte, 5*3*3)
+	for i := range pixels {
+		pixels[i] = byte(i * 5)
+	}
+	frame := decoder.NewFrame(5, 3, pixels)
+
+	assert.Equal(image.Rect(0, 0, 5, 3), frame.Bounds())
+	assert.Equal(color.RGBA{R: 105, G: 110, B: 115, A: 255}, frame.At(2, 1))
+	assert.Equal(color.RGBA{}, frame.At(5, 0))
+
+	var encoded bytes.Buffer
+	assert.NoError(png.Encode(&encoded, frame))
+	decoded, err := png.Decode(&encoded)
+	if !assert.NoError(err) {
+		return
+	}
+
+	roundTripped := decoder.NewFrameFromImage(decoded)
+	assert.Equal(5, roundTripped.Width)
+	assert.Equal(3, roundTripped.Height)
+	assert.Equal(pixels, roundTripped.Pixels())
+}
+
+func TestFrameFromImageDrawsOverBlack(t *testing.T) {
+	assert := assert.New(t)
+
+	//Images that don't start at 0,0 and aren't opaque
+	img := image.NewNRGBA(image.Rect(10, 10, 12, 11))
+	img.Set(10, 10, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+	img.Set(11, 10, color.NRGBA{R: 200, G: 100, B: 50, A: 0})
+
+	frame := decoder.NewFrameFromImage(img)
+	assert.Equal(2, frame.Width)
+	assert.Equal(1, frame.Height)
+	assert.Equal(decoder.Pixel{Red: 200, Green: 100, Blue: 50}, frame.GetRGB(0, 0))
+	assert.Equal(decoder.Pixel{}, frame.GetRGB(1, 0))
+
+	rgba := image.NewRGBA(image.Rect(3, 4, 5, 5))
+	rgba.Set(4, 4, color.RGBA{R: 1, G: 2, B: 3, A: 255})
+	rgbaFrame := decoder.NewFrameFromImage(rgba)
+	assert.Equal([]byte{0, 0, 0, 1, 2, 3}, rgbaFrame.Pixels())
+}